@@ -3,6 +3,10 @@ package cmd
 import (
 	"fmt"
 	"github.com/librucha/krmgen/internal/config"
+	"github.com/librucha/krmgen/internal/helm"
+	"github.com/librucha/krmgen/internal/template/offline"
+	"github.com/librucha/krmgen/internal/tool"
+	cons "github.com/librucha/krmgen/internal/utils"
 	"github.com/spf13/cobra"
 	"log"
 	"os"
@@ -10,6 +14,11 @@ import (
 )
 
 func NewGenerateCommand() *cobra.Command {
+	var offlineMode bool
+	var offlineFixtures string
+	var profile string
+	var chartCacheDir string
+	var chartCacheMaxSize string
 	command := &cobra.Command{
 		Use:     "generate <path>",
 		Short:   "Generate KRM by declared config",
@@ -25,15 +34,41 @@ func NewGenerateCommand() *cobra.Command {
 			if err != nil {
 				log.Fatal(err)
 			}
-			if err := processWorkDir(workDir); err != nil {
+			if offlineMode || offlineFixtures != "" {
+				if err := offline.Enable(offlineFixtures); err != nil {
+					log.Fatal(err)
+				}
+			}
+			if chartCacheDir != "" {
+				maxSize, err := tool.ParseByteSize(chartCacheMaxSize)
+				if err != nil {
+					log.Fatal(err)
+				}
+				if err := helm.EnableChartCache(chartCacheDir, maxSize); err != nil {
+					log.Fatal(err)
+				}
+			}
+			if err := processWorkDir(workDir, profile); err != nil {
 				log.Fatal(err)
 			}
 		},
 	}
+	command.Flags().StringVar(&profile, "profile", "", "name of config profile to apply. Defaults to ArgoCD env "+config.EnvProfile)
+	command.Flags().BoolVar(&offlineMode, "offline", false, "resolve secret functions to placeholders like <azSec:vault/name> instead of calling Azure")
+	command.Flags().StringVar(&offlineFixtures, "offline-fixtures", "", "YAML file with values for offline secret functions keyed like azSec:vault/name (implies --offline)")
+	command.Flags().StringVar(&chartCacheDir, "chart-cache-dir", os.Getenv(cons.EnvChartCacheDir), "dir of helm chart cache shared across runs. Only charts with exact version are cached. Defaults to env "+cons.EnvChartCacheDir)
+	command.Flags().StringVar(&chartCacheMaxSize, "chart-cache-max-size", envOrDefault(cons.EnvChartCacheMaxSize, "1Gi"), "max size of helm chart cache like 512Mi or 2G. Least recently used charts are evicted. 0 means unlimited. Defaults to env "+cons.EnvChartCacheMaxSize)
 	return command
 }
 
-func processWorkDir(workDir string) error {
+func envOrDefault(key string, defaultValue string) string {
+	if value, found := os.LookupEnv(key); found {
+		return value
+	}
+	return defaultValue
+}
+
+func processWorkDir(workDir string, profile string) error {
 	entries, err := os.ReadDir(workDir)
 	if err != nil {
 		return err
@@ -42,7 +77,7 @@ func processWorkDir(workDir string) error {
 	for _, entry := range entries {
 		filePath := workDir + "/" + entry.Name()
 		if !entry.IsDir() && config.IsConfigFile(filePath) {
-			configObject, err := config.ParseConfig(filePath)
+			configObject, err := config.ParseConfig(filePath, profile)
 			if err != nil {
 				return err
 			}
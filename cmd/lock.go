@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"fmt"
+	types "github.com/librucha/krmgen/internal"
+	"github.com/librucha/krmgen/internal/config"
+	"github.com/librucha/krmgen/internal/helm"
+	"github.com/spf13/cobra"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+func NewLockCommand() *cobra.Command {
+	command := &cobra.Command{
+		Use:   "lock <path>",
+		Short: "Resolve helm chart versions to exact versions and digests into " + helm.LockFileName,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 1 {
+				return fmt.Errorf("<path> argument required to lock charts")
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			workDir, err := filepath.Abs(args[0])
+			if err != nil {
+				log.Fatal(err)
+			}
+			if err := lockWorkDir(workDir); err != nil {
+				log.Fatal(err)
+			}
+		},
+	}
+	return command
+}
+
+// lockWorkDir locks charts of all config files in work dir with default and all declared profiles
+func lockWorkDir(workDir string) error {
+	entries, err := os.ReadDir(workDir)
+	if err != nil {
+		return err
+	}
+
+	var configs []*types.Config
+	for _, entry := range entries {
+		filePath := workDir + "/" + entry.Name()
+		if entry.IsDir() || !config.IsConfigFile(filePath) {
+			continue
+		}
+		configObject, err := config.ParseConfig(filePath, "")
+		if err != nil {
+			return err
+		}
+		configs = append(configs, configObject)
+		profiles := make([]string, 0, len(configObject.Profiles))
+		for profile := range configObject.Profiles {
+			profiles = append(profiles, profile)
+		}
+		sort.Strings(profiles)
+		for _, profile := range profiles {
+			profileConfig, err := config.ParseConfig(filePath, profile)
+			if err != nil {
+				return err
+			}
+			configs = append(configs, profileConfig)
+		}
+	}
+	lock, err := helm.LockCharts(configs, workDir)
+	if err != nil {
+		return err
+	}
+	return helm.WriteLock(workDir, lock)
+}
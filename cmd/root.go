@@ -15,5 +15,7 @@ func NewRootCommand() *cobra.Command {
 		Version: version.AppVersion,
 	}
 	command.AddCommand(NewGenerateCommand())
+	command.AddCommand(NewLockCommand())
+	command.AddCommand(NewValuesCommand())
 	return command
 }
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"github.com/librucha/krmgen/internal/config"
+	"github.com/librucha/krmgen/internal/helm"
+	"github.com/librucha/krmgen/internal/template/offline"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+	"log"
+	"path/filepath"
+)
+
+func NewValuesCommand() *cobra.Command {
+	var chartName string
+	var profile string
+	var offlineMode bool
+	var offlineFixtures string
+	command := &cobra.Command{
+		Use:   "values <config>",
+		Short: "Show merged values of helm chart validated against chart schema and defaults",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 1 {
+				return fmt.Errorf("<config> argument required to show chart values")
+			}
+			return nil
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			configFile, err := filepath.Abs(args[0])
+			if err != nil {
+				log.Fatal(err)
+			}
+			if offlineMode || offlineFixtures != "" {
+				if err := offline.Enable(offlineFixtures); err != nil {
+					log.Fatal(err)
+				}
+			}
+			configObject, err := config.ParseConfig(configFile, profile)
+			if err != nil {
+				log.Fatal(err)
+			}
+			report, err := helm.ChartValues(configObject, filepath.Dir(configFile), chartName)
+			if err != nil {
+				log.Fatal(err)
+			}
+			values, err := yaml.Marshal(report.Values)
+			if err != nil {
+				log.Fatal(err)
+			}
+			fmt.Print(string(values))
+			for _, schemaError := range report.SchemaErrors {
+				log.Printf("values do not match chart schema at %s", schemaError)
+			}
+			for _, key := range report.UnknownKeys {
+				log.Printf("valuesInline key %q does not exist in chart defaults", key)
+			}
+			if report.HasProblems() {
+				log.Fatalf("values of chart %q have %d problems", chartName, len(report.SchemaErrors)+len(report.UnknownKeys))
+			}
+		},
+	}
+	command.Flags().StringVar(&chartName, "chart", "", "release name or name of chart to show values of")
+	_ = command.MarkFlagRequired("chart")
+	command.Flags().StringVar(&profile, "profile", "", "name of config profile to apply. Defaults to ArgoCD env "+config.EnvProfile)
+	command.Flags().BoolVar(&offlineMode, "offline", false, "resolve secret functions to placeholders like <azSec:vault/name> instead of calling Azure")
+	command.Flags().StringVar(&offlineFixtures, "offline-fixtures", "", "YAML file with values for offline secret functions keyed like azSec:vault/name (implies --offline)")
+	return command
+}
@@ -6,11 +6,10 @@ import (
 	"gopkg.in/yaml.v3"
 	"log"
 	"os"
-	"regexp"
+	"path/filepath"
+	"strings"
 )
 
-var placeholderPattern = regexp.MustCompile(`\$\s*\{env:([^:]+):?(.*?)\}`)
-
 func IsConfigFile(filePath string) bool {
 	content, err := os.ReadFile(filePath)
 	if err != nil {
@@ -30,19 +29,38 @@ func IsConfigFile(filePath string) bool {
 	return false
 }
 
-func ParseConfig(filePath string) (*types.Config, error) {
+// ParseConfig evaluates and parses KrmGen config file with given profile applied.
+// Empty profile falls back to ArgoCD env PROFILE.
+func ParseConfig(filePath string, profile string) (*types.Config, error) {
 	content, err := os.ReadFile(filePath)
 	if err != nil {
 		return nil, err
 	}
 
-	var config types.Config
-
-	evalContent, err := template.EvalGoTemplates(string(content))
+	data := &template.Data{File: filePath, WorkDir: filepath.Dir(filePath)}
+	// Metadata and vars are resolved from config header first so each part of config is evaluated only once
+	header, err := parseHeader(string(content), data)
 	if err != nil {
 		return nil, err
 	}
-	if err := yaml.Unmarshal([]byte(evalContent), &config); err != nil {
+	profile = resolveProfile(profile)
+	data.Metadata = header.Metadata
+	data.Vars, err = profileVars(header, profile)
+	if err != nil {
+		return nil, err
+	}
+	data.Profile = profile
+	if err := evalProfileCharts(header.Profiles, data); err != nil {
+		return nil, err
+	}
+	config, err := evalConfig(omitSections(string(content), headerSections), data)
+	if err != nil {
+		return nil, err
+	}
+	config.Metadata = header.Metadata
+	config.Vars = header.Vars
+	config.Profiles = header.Profiles
+	if err := applyProfile(config, profile); err != nil {
 		return nil, err
 	}
 
@@ -57,5 +75,161 @@ func ParseConfig(filePath string) (*types.Config, error) {
 	//	log.Fatal(err)
 	// }
 
+	return config, nil
+}
+
+func evalConfig(content string, data *template.Data) (*types.Config, error) {
+	var config types.Config
+	evalContent, err := template.EvalFileTemplates(content, data)
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal([]byte(evalContent), &config); err != nil {
+		return nil, err
+	}
 	return &config, nil
 }
+
+// headerSections are top level config sections needed before the config is evaluated
+var headerSections = map[string]bool{"metadata": true, "vars": true, "profiles": true}
+
+// parseHeader decodes metadata, vars and profiles of config without evaluating the rest of it.
+// Templates in metadata and vars are evaluated one by one. Charts of profiles are left for evalProfileCharts.
+// Header which is not valid YAML before evaluation is evaluated as a whole.
+func parseHeader(content string, data *template.Data) (*types.Config, error) {
+	headerContent := extractSections(content, headerSections)
+	var header types.Config
+	if err := yaml.Unmarshal([]byte(headerContent), &header); err != nil {
+		evaluated, evalErr := template.EvalFileTemplates(headerContent, data)
+		if evalErr != nil {
+			return nil, evalErr
+		}
+		if err := yaml.Unmarshal([]byte(evaluated), &header); err != nil {
+			return nil, err
+		}
+		return &header, nil
+	}
+	if err := evalMetadata(header.Metadata, data); err != nil {
+		return nil, err
+	}
+	vars, err := evalVars(header.Vars, data)
+	if err != nil {
+		return nil, err
+	}
+	header.Vars = vars.(map[string]any)
+	for _, profile := range header.Profiles {
+		if profile == nil {
+			continue
+		}
+		vars, err := evalVars(profile.Vars, data)
+		if err != nil {
+			return nil, err
+		}
+		profile.Vars = vars.(map[string]any)
+	}
+	return &header, nil
+}
+
+// extractSections returns lines of given top level sections of YAML document
+func extractSections(content string, sections map[string]bool) string {
+	return filterSections(content, sections, true)
+}
+
+// omitSections returns lines of YAML document without given top level sections
+func omitSections(content string, sections map[string]bool) string {
+	return filterSections(content, sections, false)
+}
+
+// filterSections returns lines of top level sections of YAML document which are or are not in given sections.
+// Lines before the first section are kept only when sections are omitted.
+func filterSections(content string, sections map[string]bool, in bool) string {
+	var filtered strings.Builder
+	selected := !in
+	for _, line := range strings.SplitAfter(content, "\n") {
+		topLevel := line != "" && line[0] != ' ' && line[0] != '\t' && line[0] != '#' && strings.TrimSpace(line) != ""
+		if topLevel {
+			key, _, _ := strings.Cut(line, ":")
+			selected = sections[strings.TrimSpace(key)] == in
+		}
+		if selected {
+			filtered.WriteString(line)
+		}
+	}
+	return filtered.String()
+}
+
+// evalMetadata evaluates templates in labels and annotations of config metadata
+func evalMetadata(metadata *types.Metadata, data *template.Data) error {
+	if metadata == nil {
+		return nil
+	}
+	for _, values := range []map[string]string{metadata.Labels, metadata.Annotations} {
+		for key, value := range values {
+			evaluated, err := template.EvalFileTemplates(value, data)
+			if err != nil {
+				return err
+			}
+			values[key] = evaluated
+		}
+	}
+	return nil
+}
+
+// evalProfileCharts evaluates templates in chart overlays of profiles with vars of active profile
+func evalProfileCharts(profiles map[string]*types.Profile, data *template.Data) error {
+	for _, profile := range profiles {
+		if profile == nil || len(profile.Charts) == 0 {
+			continue
+		}
+		content, err := yaml.Marshal(profile.Charts)
+		if err != nil {
+			return err
+		}
+		var charts any
+		if err := yaml.Unmarshal(content, &charts); err != nil {
+			return err
+		}
+		evaluated, err := evalVars(charts, data)
+		if err != nil {
+			return err
+		}
+		if content, err = yaml.Marshal(evaluated); err != nil {
+			return err
+		}
+		profile.Charts = nil
+		if err := yaml.Unmarshal(content, &profile.Charts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// evalVars evaluates templates in string values of vars
+func evalVars(value any, data *template.Data) (any, error) {
+	switch typed := value.(type) {
+	case map[string]any:
+		result := make(map[string]any, len(typed))
+		for k, v := range typed {
+			evaluated, err := evalVars(v, data)
+			if err != nil {
+				return nil, err
+			}
+			result[k] = evaluated
+		}
+		return result, nil
+	case []any:
+		result := make([]any, len(typed))
+		for i, v := range typed {
+			evaluated, err := evalVars(v, data)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = evaluated
+		}
+		return result, nil
+	case string:
+		return template.EvalFileTemplates(typed, data)
+	default:
+		return value, nil
+	}
+}
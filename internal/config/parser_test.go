@@ -4,6 +4,7 @@ import (
 	"github.com/librucha/krmgen/internal"
 	"log"
 	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 )
@@ -59,6 +60,18 @@ func TestParseConfig(t *testing.T) {
 	_ = os.Setenv("ARGOCD_APP_REL_PROFILE", "test0")
 	type args struct {
 		filePath string
+		profile  string
+	}
+	profiles := map[string]*types.Profile{
+		"prod": {
+			Vars: map[string]any{"replicas": 3},
+			Charts: map[string]*types.ProfileChart{
+				"profiled-app": {
+					Version:      "2.0.0",
+					ValuesInline: map[string]any{"logging": map[string]any{"level": "warn"}},
+				},
+			},
+		},
 	}
 	tests := []struct {
 		name    string
@@ -76,6 +89,7 @@ func TestParseConfig(t *testing.T) {
 					Annotations: map[string]string{"krmgen.io/plugin": "some-plugin"},
 					Labels:      map[string]string{"app.kubernetes.io/name": "krmgen-controller"},
 				},
+				Vars: map[string]any{"appVersion": "1.0.0"},
 				Helm: &types.Helm{
 					Charts: &[]types.HelmChart{
 						{
@@ -97,10 +111,68 @@ func TestParseConfig(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "default profile",
+			args: args{filePath: "../../test/resources/profiles/profiles-krmgen-config.yaml"},
+			want: &types.Config{
+				ApiVersion: "krmgen.config.librucha.com/v1alpha1",
+				Kind:       "KrmGen",
+				Vars:       map[string]any{"region": "westeurope", "replicas": 1},
+				Profiles:   profiles,
+				Helm: &types.Helm{
+					Charts: &[]types.HelmChart{
+						{
+							Name:        "helm-app",
+							RepoUrl:     "https://helm.registry.io/helm/",
+							ReleaseName: "profiled-app",
+							Version:     "1.0.0",
+							ValuesInline: map[string]any{
+								"region":   "westeurope",
+								"replicas": "1",
+								"profile":  "",
+								"logging":  map[string]any{"enabled": true, "level": "debug"},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "prod profile",
+			args: args{filePath: "../../test/resources/profiles/profiles-krmgen-config.yaml", profile: "prod"},
+			want: &types.Config{
+				ApiVersion: "krmgen.config.librucha.com/v1alpha1",
+				Kind:       "KrmGen",
+				Vars:       map[string]any{"region": "westeurope", "replicas": 3},
+				Profiles:   profiles,
+				Profile:    "prod",
+				Helm: &types.Helm{
+					Charts: &[]types.HelmChart{
+						{
+							Name:        "helm-app",
+							RepoUrl:     "https://helm.registry.io/helm/",
+							ReleaseName: "profiled-app",
+							Version:     "2.0.0",
+							ValuesInline: map[string]any{
+								"region":   "westeurope",
+								"replicas": "3",
+								"profile":  "prod",
+								"logging":  map[string]any{"enabled": true, "level": "warn"},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			name:    "unknown profile",
+			args:    args{filePath: "../../test/resources/profiles/profiles-krmgen-config.yaml", profile: "unknown"},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := ParseConfig(tt.args.filePath)
+			got, err := ParseConfig(tt.args.filePath, tt.args.profile)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("ParseConfig() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -111,3 +183,72 @@ func TestParseConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestParseConfig_varsPipeline(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), "krmgen.yaml")
+	content := `apiVersion: krmgen.config.librucha.com/v1alpha1
+kind: KrmGen
+metadata:
+  labels:
+    env: '{{ argocdEnv "MISSING_ENV" "dev" }}'
+vars:
+  region: '{{ argocdEnv "MISSING_REGION" "westeurope" }}'
+  token: '{{ randAlphaNum 16 }}'
+profiles:
+  dev:
+    vars:
+      region: '{{ "northeurope" }}'
+    charts:
+      NORTHEUROPE-dev:
+        version: '{{ .Vars.region }}'
+helm:
+  charts:
+    - name: helm-app
+      repo: https://helm.registry.io/helm/
+      releaseName: '{{ upper .Vars.region }}-{{ .Metadata.Labels.env }}'
+      valuesInline:
+        token: '{{ .Vars.token }}'
+`
+	if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name        string
+		profile     string
+		want        string
+		wantVersion string
+	}{
+		{
+			name: "default vars",
+			want: "WESTEUROPE-dev",
+		},
+		{
+			name:        "profile vars",
+			profile:     "dev",
+			want:        "NORTHEUROPE-dev",
+			wantVersion: "northeurope",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseConfig(configFile, tt.profile)
+			if err != nil {
+				t.Fatalf("ParseConfig() error = %v", err)
+			}
+			chart := (*got.Helm.Charts)[0]
+			if chart.ReleaseName != tt.want {
+				t.Errorf("ParseConfig() release name = %v, want %v", chart.ReleaseName, tt.want)
+			}
+			if chart.Version != tt.wantVersion {
+				t.Errorf("ParseConfig() version = %v, want %v", chart.Version, tt.wantVersion)
+			}
+			if env := got.Metadata.Labels["env"]; env != "dev" {
+				t.Errorf("ParseConfig() metadata label env = %v, want dev", env)
+			}
+			// vars are evaluated once so config vars are the vars seen by templates
+			if token := chart.ValuesInline["token"]; token != got.Vars["token"] {
+				t.Errorf("ParseConfig() token in values = %v, config vars token = %v", token, got.Vars["token"])
+			}
+		})
+	}
+}
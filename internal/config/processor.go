@@ -4,24 +4,28 @@ import (
 	"github.com/librucha/krmgen/internal"
 	"github.com/librucha/krmgen/internal/helm"
 	"github.com/librucha/krmgen/internal/kustomize"
+	"github.com/librucha/krmgen/internal/placeholder"
 	"strings"
 )
 
 func ProcessConfig(config *types.Config, workDir string) (string, error) {
-	resources := strings.Builder{}
+	var renderedCharts []types.RenderedChart
 	if config.HasHelm() {
-		helmCharts, err := helm.TemplateHelmCharts(config.Helm, workDir)
+		var err error
+		renderedCharts, err = helm.TemplateHelmCharts(config, workDir)
 		if err != nil {
 			return "", err
 		}
-		resources.WriteString(helmCharts)
 	}
+	resources := strings.Builder{}
 	kustomizeFile := kustomize.FindKustomizeFile(workDir)
 	if kustomizeFile != "" {
-		kustomizeResources := kustomize.BuildKustomize(kustomizeFile, workDir, resources.String())
-		resources.Reset()
-		resources.WriteString(kustomizeResources)
+		resources.WriteString(kustomize.BuildKustomize(config, kustomizeFile, workDir, renderedCharts))
+	} else {
+		for _, renderedChart := range renderedCharts {
+			resources.WriteString(renderedChart.Manifest)
+		}
 	}
 
-	return resources.String(), nil
+	return placeholder.EvalVaultPlaceholders(resources.String())
 }
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+	types "github.com/librucha/krmgen/internal"
+	"github.com/librucha/krmgen/internal/template/argocd"
+	"github.com/librucha/krmgen/internal/tool"
+	"os"
+)
+
+const EnvProfile = argocd.EnvEnvKeyPrefix + "PROFILE"
+
+// resolveProfile returns given profile name or the one from ArgoCD env
+func resolveProfile(profile string) string {
+	if profile != "" {
+		return profile
+	}
+	return os.Getenv(EnvProfile)
+}
+
+// profileVars returns config vars overlaid by vars of named profile
+func profileVars(config *types.Config, name string) (map[string]any, error) {
+	if name == "" {
+		return config.Vars, nil
+	}
+	profile, found := config.Profiles[name]
+	if !found {
+		return nil, fmt.Errorf("profile %q is not defined in config", name)
+	}
+	if profile == nil {
+		return config.Vars, nil
+	}
+	return tool.MergeMaps(config.Vars, profile.Vars), nil
+}
+
+// applyProfile overlays vars, chart versions and values of named profile onto config
+func applyProfile(config *types.Config, name string) error {
+	if name == "" {
+		return nil
+	}
+	vars, err := profileVars(config, name)
+	if err != nil {
+		return err
+	}
+	config.Vars = vars
+	config.Profile = name
+	profile := config.Profiles[name]
+	if profile == nil || len(profile.Charts) == 0 {
+		return nil
+	}
+	if !config.HasHelm() {
+		return fmt.Errorf("profile %q overlays charts but config has no helm charts", name)
+	}
+	charts := *config.Helm.Charts
+	for releaseName, overlay := range profile.Charts {
+		found := false
+		for i := range charts {
+			if charts[i].ReleaseName != releaseName {
+				continue
+			}
+			found = true
+			if overlay == nil {
+				continue
+			}
+			if overlay.Version != "" {
+				charts[i].Version = overlay.Version
+			}
+			if len(overlay.ValuesInline) > 0 {
+				charts[i].ValuesInline = tool.MergeMaps(charts[i].ValuesInline, overlay.ValuesInline)
+			}
+		}
+		if !found {
+			return fmt.Errorf("profile %q overlays unknown chart release %q", name, releaseName)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package helm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/librucha/krmgen/internal/template/offline"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const acrHostSuffix = ".azurecr.io"
+
+// acrRefreshTokenUser is the user name ACR expects together with refresh token
+const acrRefreshTokenUser = "00000000-0000-0000-0000-000000000000"
+
+const acrTokenScope = "https://management.azure.com/.default"
+
+const envAzureTenantId = "AZURE_TENANT_ID"
+
+// acrTimeout limits Azure token request and ACR token exchange so unresponsive endpoint does not block generation
+var acrTimeout = 30 * time.Second
+
+// newAcrCredential returns Azure identity used for ACR token exchange
+var newAcrCredential = func() (azcore.TokenCredential, error) {
+	return azidentity.NewDefaultAzureCredential(nil)
+}
+
+var cachedAcrTokens = make(map[string]string, 5)
+
+// acrCredentials fills ACR refresh token as credentials of oci chart hosted on ACR without credentials
+func acrCredentials(generator generator) error {
+	oci, ok := generator.(ociHelmGenerator)
+	config := generator.getConfig()
+	if !ok || credentialsProvided(config) || !isAcrHost(oci.chartIdShort()) {
+		return nil
+	}
+	host := oci.chartIdShort()
+	if offline.Enabled() {
+		// like secret functions offline mode never calls Azure. Chart is pulled anonymously or taken from chart cache.
+		log.Printf("azure token exchange for registry %q skipped in offline mode", host)
+		return nil
+	}
+	refreshToken := cachedAcrTokens[host]
+	if refreshToken == "" {
+		cred, err := newAcrCredential()
+		if err != nil {
+			return fmt.Errorf("azure identity for registry %q not available error: %s", host, err)
+		}
+		refreshToken, err = exchangeAcrRefreshToken(&http.Client{Timeout: acrTimeout}, "https://"+host, host, cred)
+		if err != nil {
+			return err
+		}
+		cachedAcrTokens[host] = refreshToken
+	}
+	config.Username = acrRefreshTokenUser
+	config.Password = refreshToken
+	return nil
+}
+
+// exchangeAcrRefreshToken exchanges AAD access token for ACR refresh token
+func exchangeAcrRefreshToken(client *http.Client, endpoint string, service string, cred azcore.TokenCredential) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), acrTimeout)
+	defer cancel()
+	accessToken, err := cred.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{acrTokenScope}})
+	if err != nil {
+		return "", fmt.Errorf("getting azure token for registry %q failed error: %s", service, err)
+	}
+	form := url.Values{
+		"grant_type":   {"access_token"},
+		"service":      {service},
+		"access_token": {accessToken.Token},
+	}
+	if tenant := os.Getenv(envAzureTenantId); tenant != "" {
+		form.Set("tenant", tenant)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(endpoint, "/")+"/oauth2/exchange", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	res, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token exchange with registry %q failed error: %s", service, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token exchange with registry %q failed status: %s", service, res.Status)
+	}
+	var body struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil || body.RefreshToken == "" {
+		return "", fmt.Errorf("token exchange with registry %q returned no refresh token", service)
+	}
+	return body.RefreshToken, nil
+}
+
+func isAcrHost(host string) bool {
+	return strings.HasSuffix(strings.ToLower(host), acrHostSuffix)
+}
@@ -0,0 +1,138 @@
+package helm
+
+import (
+	"context"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	types "github.com/librucha/krmgen/internal"
+	"github.com/librucha/krmgen/internal/template/offline"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type fakeCredential struct{}
+
+func (f *fakeCredential) GetToken(_ context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{Token: "aad-token", ExpiresOn: time.Now().Add(time.Hour).UTC()}, nil
+}
+
+func Test_exchangeAcrRefreshToken(t *testing.T) {
+	t.Setenv(envAzureTenantId, "tenant-id")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		if r.URL.Path != "/oauth2/exchange" ||
+			r.PostForm.Get("grant_type") != "access_token" ||
+			r.PostForm.Get("access_token") != "aad-token" ||
+			r.PostForm.Get("tenant") != "tenant-id" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.PostForm.Get("service") == "empty.azurecr.io" {
+			_, _ = w.Write([]byte(`{}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"refresh_token":"acr-refresh-token"}`))
+	}))
+	defer server.Close()
+
+	got, err := exchangeAcrRefreshToken(server.Client(), server.URL, "myacr.azurecr.io", &fakeCredential{})
+	if err != nil || got != "acr-refresh-token" {
+		t.Errorf("exchangeAcrRefreshToken() got = %v, %v, want acr-refresh-token", got, err)
+	}
+	if _, err := exchangeAcrRefreshToken(server.Client(), server.URL, "empty.azurecr.io", &fakeCredential{}); err == nil {
+		t.Errorf("exchangeAcrRefreshToken() expected error for missing refresh token")
+	}
+	if _, err := exchangeAcrRefreshToken(server.Client(), server.URL+"/unknown", "myacr.azurecr.io", &fakeCredential{}); err == nil {
+		t.Errorf("exchangeAcrRefreshToken() expected error for rejected exchange")
+	}
+}
+
+// slowCredential returns token only after request context is done
+type slowCredential struct{}
+
+func (f *slowCredential) GetToken(ctx context.Context, _ policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	<-ctx.Done()
+	return azcore.AccessToken{}, ctx.Err()
+}
+
+func Test_exchangeAcrRefreshToken_timeout(t *testing.T) {
+	timeout := acrTimeout
+	defer func() { acrTimeout = timeout }()
+	acrTimeout = 50 * time.Millisecond
+
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	if _, err := exchangeAcrRefreshToken(server.Client(), server.URL, "myacr.azurecr.io", &slowCredential{}); err == nil {
+		t.Errorf("exchangeAcrRefreshToken() expected error for azure token timeout")
+	}
+	if _, err := exchangeAcrRefreshToken(server.Client(), server.URL, "myacr.azurecr.io", &fakeCredential{}); err == nil {
+		t.Errorf("exchangeAcrRefreshToken() expected error for unresponsive registry")
+	}
+}
+
+func Test_acrCredentials(t *testing.T) {
+	cachedAcrTokens["myacr.azurecr.io"] = "acr-refresh-token"
+	defer delete(cachedAcrTokens, "myacr.azurecr.io")
+
+	tests := []struct {
+		name         string
+		config       *types.HelmChart
+		offline      bool
+		wantUsername string
+		wantPassword string
+	}{
+		{
+			name:         "acr without credentials",
+			config:       &types.HelmChart{Name: "app", RepoUrl: "oci://myacr.azurecr.io/helm"},
+			wantUsername: acrRefreshTokenUser,
+			wantPassword: "acr-refresh-token",
+		},
+		{
+			name:         "acr with credentials",
+			config:       &types.HelmChart{Name: "app", RepoUrl: "oci://myacr.azurecr.io/helm", Username: "admin", Password: "password"},
+			wantUsername: "admin",
+			wantPassword: "password",
+		},
+		{
+			name:   "other registry",
+			config: &types.HelmChart{Name: "app", RepoUrl: "oci://registry.example.com/helm"},
+		},
+		{
+			name:   "helm repo on acr host",
+			config: &types.HelmChart{Name: "app", RepoUrl: "https://myacr.azurecr.io/helm/v1/repo"},
+		},
+		{
+			name:    "acr in offline mode",
+			config:  &types.HelmChart{Name: "app", RepoUrl: "oci://otheracr.azurecr.io/helm"},
+			offline: true,
+		},
+	}
+	newCredential := newAcrCredential
+	defer func() { newAcrCredential = newCredential }()
+	newAcrCredential = func() (azcore.TokenCredential, error) {
+		t.Errorf("azure identity must not be used")
+		return &fakeCredential{}, nil
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.offline {
+				_ = offline.Enable("")
+				defer offline.Disable()
+			}
+			generator, _ := newGenerator(tt.config, "/work")
+			if err := acrCredentials(generator); err != nil {
+				t.Fatalf("acrCredentials() error = %v", err)
+			}
+			if tt.config.Username != tt.wantUsername || tt.config.Password != tt.wantPassword {
+				t.Errorf("acrCredentials() got = %v/%v, want %v/%v", tt.config.Username, tt.config.Password, tt.wantUsername, tt.wantPassword)
+			}
+		})
+	}
+}
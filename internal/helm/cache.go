@@ -0,0 +1,314 @@
+package helm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/Masterminds/semver/v3"
+	types "github.com/librucha/krmgen/internal"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// cacheable is implemented by generators which charts can be pulled by helm and stored in chart cache
+type cacheable interface {
+	// addPullArgs adds chart reference args of helm pull command
+	addPullArgs([]string) []string
+}
+
+// chartCache stores pulled charts content-addressed by sha256 digest.
+// Layout is <dir>/refs/<sha256 of repo, name and version> containing chart digest
+// and <dir>/blobs/<digest>.tgz with chart package.
+type chartCache struct {
+	dir     string
+	maxSize int64
+}
+
+var activeChartCache *chartCache
+
+// EnableChartCache turns on chart cache in given dir. Least recently used charts are evicted when
+// cache exceeds maxSize bytes. Zero maxSize means unlimited cache.
+func EnableChartCache(dir string, maxSize int64) error {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	c := &chartCache{dir: absDir, maxSize: maxSize}
+	for _, d := range []string{c.refsDir(), c.blobsDir()} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			return fmt.Errorf("creating chart cache dir %q failed error: %s", d, err)
+		}
+	}
+	activeChartCache = c
+	return nil
+}
+
+// DisableChartCache turns off chart cache
+func DisableChartCache() {
+	activeChartCache = nil
+}
+
+// cachedChartArgs returns chart args from enabled cache. Returns nil args on cache miss or if chart cannot be cached.
+// Cache is read before login to repository so cached chart needs no network and credentials.
+func cachedChartArgs(generator generator, tempDir string) []string {
+	if !cachesChart(generator) {
+		return nil
+	}
+	chartFile := activeChartCache.find(generator.getConfig(), tempDir)
+	if chartFile == "" {
+		return nil
+	}
+	return []string{chartFile}
+}
+
+// cachesChart returns true if chart of generator is stored in enabled cache
+func cachesChart(generator generator) bool {
+	_, ok := generator.(cacheable)
+	config := generator.getConfig()
+	if activeChartCache == nil || !ok {
+		return false
+	}
+	// cached package has no signature to check so verified charts are pulled and verified on every render
+	return !config.Verify && (config.Digest != "" || isExactVersion(config.Version))
+}
+
+// find copies verified chart from cache into temp dir. Chart is looked up by locked digest first
+// and by repo, name and exact version next. Returns empty file on miss.
+func (c *chartCache) find(config *types.HelmChart, tempDir string) string {
+	var digests []string
+	if digest, locked := strings.CutPrefix(config.Digest, digestPrefix); locked {
+		digests = append(digests, digest)
+	}
+	if isExactVersion(config.Version) {
+		if digest := c.lookup(cacheKey(config)); digest != "" {
+			digests = append(digests, digest)
+		}
+	}
+	chartFile := filepath.Join(tempDir, "chart-"+config.ReleaseName+".tgz")
+	for _, digest := range digests {
+		if _, err := os.Stat(c.blobFile(digest)); err != nil {
+			continue
+		}
+		err := c.copyVerified(digest, chartFile)
+		if err == nil {
+			return chartFile
+		}
+		log.Printf("cached chart %q version %q is not usable and will be pulled again: %s", config.Name, config.Version, err)
+		_ = os.Remove(c.blobFile(digest))
+	}
+	return ""
+}
+
+// put stores pulled chart to cache. Chart of exact version is linked by repo, name and version too.
+func (c *chartCache) put(config *types.HelmChart, chartFile string) error {
+	digest, err := c.storeBlob(chartFile)
+	if err != nil {
+		return err
+	}
+	if isExactVersion(config.Version) {
+		if err := c.storeRef(cacheKey(config), digest); err != nil {
+			return err
+		}
+	}
+	c.evict()
+	return nil
+}
+
+// lookup returns digest of cached chart or empty string
+func (c *chartCache) lookup(key string) string {
+	content, err := os.ReadFile(filepath.Join(c.refsDir(), key))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(content))
+}
+
+// copyVerified copies cached blob to target file and checks its digest
+func (c *chartCache) copyVerified(digest string, targetFile string) error {
+	blobFile := c.blobFile(digest)
+	source, err := os.Open(blobFile)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	target, err := os.Create(targetFile)
+	if err != nil {
+		return err
+	}
+	defer target.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(target, hash), source); err != nil {
+		return err
+	}
+	if actual := hex.EncodeToString(hash.Sum(nil)); actual != digest {
+		return fmt.Errorf("digest mismatch expected sha256:%s but got sha256:%s", digest, actual)
+	}
+	now := time.Now()
+	_ = os.Chtimes(blobFile, now, now)
+	return nil
+}
+
+// storeBlob saves file to cache under its digest
+func (c *chartCache) storeBlob(file string) (string, error) {
+	digest, err := fileDigest(file)
+	if err != nil {
+		return "", err
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	if err := writeFileAtomic(c.blobFile(digest), content); err != nil {
+		return "", fmt.Errorf("storing chart to cache failed error: %s", err)
+	}
+	return digest, nil
+}
+
+// storeRef links the key to stored content
+func (c *chartCache) storeRef(key string, content string) error {
+	if err := writeFileAtomic(filepath.Join(c.refsDir(), key), []byte(content)); err != nil {
+		return fmt.Errorf("storing chart to cache failed error: %s", err)
+	}
+	return nil
+}
+
+// evict removes least recently used blobs until cache fits max size
+func (c *chartCache) evict() {
+	if c.maxSize <= 0 {
+		return
+	}
+	entries, err := os.ReadDir(c.blobsDir())
+	if err != nil {
+		return
+	}
+	var blobs []os.FileInfo
+	var totalSize int64
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || info.IsDir() || !strings.HasSuffix(info.Name(), ".tgz") {
+			continue
+		}
+		blobs = append(blobs, info)
+		totalSize += info.Size()
+	}
+	sort.Slice(blobs, func(i, j int) bool {
+		return blobs[i].ModTime().Before(blobs[j].ModTime())
+	})
+	evicted := map[string]bool{}
+	for _, blob := range blobs {
+		if totalSize <= c.maxSize {
+			break
+		}
+		if err := os.Remove(filepath.Join(c.blobsDir(), blob.Name())); err == nil {
+			totalSize -= blob.Size()
+			evicted[strings.TrimSuffix(blob.Name(), ".tgz")] = true
+		}
+	}
+	c.pruneRefs(evicted)
+}
+
+// pruneRefs removes refs pointing to any of evicted digests. Ref content has digest at start of each line.
+func (c *chartCache) pruneRefs(evicted map[string]bool) {
+	if len(evicted) == 0 {
+		return
+	}
+	entries, err := os.ReadDir(c.refsDir())
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		for _, line := range strings.Split(c.lookup(entry.Name()), "\n") {
+			digest, _, _ := strings.Cut(line, " ")
+			if evicted[digest] {
+				_ = os.Remove(filepath.Join(c.refsDir(), entry.Name()))
+				break
+			}
+		}
+	}
+}
+
+func (c *chartCache) refsDir() string {
+	return filepath.Join(c.dir, "refs")
+}
+
+func (c *chartCache) blobsDir() string {
+	return filepath.Join(c.dir, "blobs")
+}
+
+func (c *chartCache) blobFile(digest string) string {
+	return filepath.Join(c.blobsDir(), digest+".tgz")
+}
+
+// pullChart downloads chart package by helm pull into temp dir
+func pullChart(generator generator, puller cacheable, env *helmEnv, tempDir string) (string, error) {
+	config := generator.getConfig()
+	pullDir, err := os.MkdirTemp(tempDir, "pull")
+	if err != nil {
+		return "", err
+	}
+	args := puller.addPullArgs([]string{"pull"})
+	args = append(args, "--version", config.Version, "--destination", pullDir)
+	args = addVerifyArgs(generator, args)
+	output, err := env.run(args...)
+	if err != nil {
+		return "", err
+	}
+	if err := verifyPull(generator, env, output); err != nil {
+		return "", err
+	}
+	pulled, err := filepath.Glob(filepath.Join(pullDir, "*.tgz"))
+	if err != nil || len(pulled) != 1 {
+		return "", fmt.Errorf("helm pull of chart %q version %q did not produce chart package", config.Name, config.Version)
+	}
+	return pulled[0], nil
+}
+
+// cacheKey returns file name safe key of chart repo, name and version
+func cacheKey(config *types.HelmChart) string {
+	hash := sha256.Sum256([]byte(strings.Join([]string{config.RepoUrl, config.Name, config.Version}, "\n")))
+	return hex.EncodeToString(hash[:])
+}
+
+// isExactVersion returns true for version without range so cached chart can not change
+func isExactVersion(version string) bool {
+	_, err := semver.StrictNewVersion(strings.TrimPrefix(version, "v"))
+	return err == nil
+}
+
+func fileDigest(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// writeFileAtomic writes file by rename so concurrent readers never see partial content
+func writeFileAtomic(file string, content []byte) error {
+	temp, err := os.CreateTemp(filepath.Dir(file), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(content); err != nil {
+		_ = temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), file)
+}
@@ -0,0 +1,202 @@
+package helm
+
+import (
+	"fmt"
+	types "github.com/librucha/krmgen/internal"
+	cons "github.com/librucha/krmgen/internal/utils"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeHelmPull creates helm executable writing chart package to --destination and counting pulls
+func fakeHelmPull(t *testing.T) string {
+	binDir := t.TempDir()
+	pullsFile := filepath.Join(binDir, "pulls")
+	script := fmt.Sprintf(`#!/bin/sh
+echo "$@" >> %q
+while [ "$#" -gt 0 ]; do
+  case "$1" in
+    --version) version="$2"; shift ;;
+    --destination) destination="$2"; shift ;;
+  esac
+  shift
+done
+echo "chart $version" > "$destination/app-$version.tgz"
+echo "Digest: sha256:digest-$version"
+`, pullsFile)
+	helmFile := filepath.Join(binDir, "helm")
+	if err := os.WriteFile(helmFile, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(cons.EnvHelmExecutable, helmFile)
+	return pullsFile
+}
+
+func testHelmEnv(t *testing.T) *helmEnv {
+	env, err := newHelmEnv(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return env
+}
+
+func countPulls(pullsFile string) int {
+	content, _ := os.ReadFile(pullsFile)
+	return strings.Count(string(content), "\n")
+}
+
+func Test_chartCache_get(t *testing.T) {
+	pullsFile := fakeHelmPull(t)
+	cacheDir := t.TempDir()
+	if err := EnableChartCache(cacheDir, 0); err != nil {
+		t.Fatal(err)
+	}
+	defer DisableChartCache()
+
+	config := &types.HelmChart{Name: "app", RepoUrl: "https://charts.example.com", ReleaseName: "app", Version: "1.0.0"}
+	generator := newRepoHelmGenerator(config)
+
+	args, err := getChartArgs(generator, testHelmEnv(t), t.TempDir())
+	if err != nil {
+		t.Fatalf("getChartArgs() error = %v", err)
+	}
+	if content, _ := os.ReadFile(args[0]); string(content) != "chart 1.0.0\n" {
+		t.Errorf("getChartArgs() pulled chart content = %q", content)
+	}
+	if countPulls(pullsFile) != 1 {
+		t.Errorf("getChartArgs() pulls = %d, want 1", countPulls(pullsFile))
+	}
+
+	args, err = getChartArgs(generator, testHelmEnv(t), t.TempDir())
+	if err != nil {
+		t.Fatalf("getChartArgs() error = %v", err)
+	}
+	if content, _ := os.ReadFile(args[0]); string(content) != "chart 1.0.0\n" {
+		t.Errorf("getChartArgs() cached chart content = %q", content)
+	}
+	if countPulls(pullsFile) != 1 {
+		t.Errorf("getChartArgs() cache hit pulls = %d, want 1", countPulls(pullsFile))
+	}
+
+	// corrupted blob fails digest verification and is pulled again
+	blobs, _ := filepath.Glob(filepath.Join(cacheDir, "blobs", "*.tgz"))
+	if len(blobs) != 1 {
+		t.Fatalf("cache blobs = %v, want 1", blobs)
+	}
+	_ = os.WriteFile(blobs[0], []byte("tampered"), 0644)
+	args, err = getChartArgs(generator, testHelmEnv(t), t.TempDir())
+	if err != nil {
+		t.Fatalf("getChartArgs() error = %v", err)
+	}
+	if content, _ := os.ReadFile(args[0]); string(content) != "chart 1.0.0\n" {
+		t.Errorf("getChartArgs() re-pulled chart content = %q", content)
+	}
+	if countPulls(pullsFile) != 2 {
+		t.Errorf("getChartArgs() corrupted cache pulls = %d, want 2", countPulls(pullsFile))
+	}
+
+	// version range is never cached
+	rangeConfig := &types.HelmChart{Name: "app", RepoUrl: "https://charts.example.com", ReleaseName: "app", Version: "^1.0.0"}
+	args, err = getChartArgs(newRepoHelmGenerator(rangeConfig), testHelmEnv(t), t.TempDir())
+	if err != nil {
+		t.Fatalf("getChartArgs() error = %v", err)
+	}
+	want := []string{"--version", "^1.0.0", "--repo", "https://charts.example.com", "--release-name", "app"}
+	if strings.Join(args, " ") != strings.Join(want, " ") {
+		t.Errorf("getChartArgs() = %v, want %v", args, want)
+	}
+}
+
+func Test_getChartArgs_cacheHitWithoutLogin(t *testing.T) {
+	callsFile := fakeHelmPull(t)
+	if err := EnableChartCache(t.TempDir(), 0); err != nil {
+		t.Fatal(err)
+	}
+	defer DisableChartCache()
+
+	digest := sha256Digest([]byte("chart 1.0.0\n"))
+	config := &types.HelmChart{Name: "app", RepoUrl: "https://charts.example.com", ReleaseName: "app", Version: "1.0.0", Digest: digest, Username: "user", Password: "password"}
+	if _, err := getChartArgs(newRepoHelmGenerator(config), testHelmEnv(t), t.TempDir()); err != nil {
+		t.Fatalf("getChartArgs() error = %v", err)
+	}
+	if calls, _ := os.ReadFile(callsFile); !strings.HasPrefix(string(calls), "repo add") || countPulls(callsFile) != 2 {
+		t.Fatalf("getChartArgs() helm calls = %q, want login and pull", calls)
+	}
+
+	// chart locked by digest is served from cache even from other repo and without helm calls
+	mirrorConfig := &types.HelmChart{Name: "app", RepoUrl: "https://mirror.example.com", ReleaseName: "app", Version: "1.0.0", Digest: digest, Username: "user", Password: "password"}
+	for _, config := range []*types.HelmChart{config, mirrorConfig} {
+		args, err := getChartArgs(newRepoHelmGenerator(config), testHelmEnv(t), t.TempDir())
+		if err != nil {
+			t.Fatalf("getChartArgs() error = %v", err)
+		}
+		if content, _ := os.ReadFile(args[0]); string(content) != "chart 1.0.0\n" {
+			t.Errorf("getChartArgs() cached chart content = %q", content)
+		}
+		if countPulls(callsFile) != 2 {
+			t.Errorf("getChartArgs() cache hit of %q helm calls = %d, want 2", config.RepoUrl, countPulls(callsFile))
+		}
+	}
+}
+
+func Test_chartCache_evict(t *testing.T) {
+	pullsFile := fakeHelmPull(t)
+	cacheDir := t.TempDir()
+	// every fake chart has 12 bytes so only two fit
+	if err := EnableChartCache(cacheDir, 24); err != nil {
+		t.Fatal(err)
+	}
+	defer DisableChartCache()
+
+	pull := func(version string) {
+		config := &types.HelmChart{Name: "app", RepoUrl: "oci://registry.example.com/charts/app", ReleaseName: "app", Version: version}
+		if _, err := getChartArgs(newOciHelmGenerator(config), testHelmEnv(t), t.TempDir()); err != nil {
+			t.Fatalf("getChartArgs() error = %v", err)
+		}
+	}
+	pull("1.0.0")
+	pull("1.0.1")
+	pull("1.0.0")
+	pull("1.0.2")
+	if countPulls(pullsFile) != 3 {
+		t.Fatalf("pulls = %d, want 3", countPulls(pullsFile))
+	}
+	// 1.0.1 is least recently used and was evicted with its ref
+	refs, _ := os.ReadDir(filepath.Join(cacheDir, "refs"))
+	if len(refs) != 2 {
+		t.Errorf("refs after eviction = %d, want 2", len(refs))
+	}
+	pull("1.0.1")
+	if countPulls(pullsFile) != 4 {
+		t.Errorf("pulls after eviction = %d, want 4", countPulls(pullsFile))
+	}
+	pull("1.0.2")
+	if countPulls(pullsFile) != 4 {
+		t.Errorf("pulls of cached chart = %d, want 4", countPulls(pullsFile))
+	}
+}
+
+func Test_isExactVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		want    bool
+	}{
+		{version: "1.2.3", want: true},
+		{version: "v1.2.3", want: true},
+		{version: "1.2.3-rc.1", want: true},
+		{version: "", want: false},
+		{version: "1.2", want: false},
+		{version: "^1.2.3", want: false},
+		{version: ">=1.0.0 <2.0.0", want: false},
+		{version: "1.x", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			if got := isExactVersion(tt.version); got != tt.want {
+				t.Errorf("isExactVersion() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,312 @@
+package helm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	types "github.com/librucha/krmgen/internal"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// chartSource is implemented by generators templating chart directory which dependencies may need to be built
+type chartSource interface {
+	// sourceDir returns chart directory or package
+	sourceDir() string
+	// useDir replaces chart directory by its copy
+	useDir(chartDir string)
+}
+
+type chartDependency struct {
+	Name       string `yaml:"name"`
+	Alias      string `yaml:"alias"`
+	Version    string `yaml:"version"`
+	Repository string `yaml:"repository"`
+}
+
+// buildDependencies runs helm dependency build for chart directory with missing dependencies.
+// Charts out of temp dir are copied first so work dir is not modified.
+func buildDependencies(generator generator, env *helmEnv, tempDir string) error {
+	source, ok := generator.(chartSource)
+	if !ok {
+		return nil
+	}
+	config := generator.getConfig()
+	chartDir := source.sourceDir()
+	dependencies, err := readDependencies(chartDir)
+	if err != nil {
+		return err
+	}
+	if !hasMissingDependencies(chartDir, dependencies) {
+		return nil
+	}
+	if !strings.HasPrefix(chartDir, tempDir+string(filepath.Separator)) {
+		chartCopy := filepath.Join(tempDir, "chart-"+config.ReleaseName)
+		if err := copyDir(chartDir, chartCopy); err != nil {
+			return fmt.Errorf("copying chart %q failed error: %s", chartDir, err)
+		}
+		source.useDir(chartCopy)
+		chartDir = chartCopy
+	}
+
+	chartsDir := filepath.Join(chartDir, "charts")
+	cacheKey := dependenciesCacheKey(config.LockedDependencies)
+	if activeChartCache != nil && cacheKey != "" && activeChartCache.getDependencies(cacheKey, chartsDir) {
+		return nil
+	}
+	if err := addDependencyRepos(config, env, dependencies, tempDir); err != nil {
+		return err
+	}
+	if _, err := env.run("dependency", "build", chartDir); err != nil {
+		return fmt.Errorf("building dependencies of chart %q failed: %s", config.Name, err)
+	}
+	if err := verifyLockedDependencies(config, chartsDir); err != nil {
+		return err
+	}
+	if activeChartCache != nil && cacheKey != "" {
+		if err := activeChartCache.storeDependencies(cacheKey, chartsDir); err != nil {
+			log.Printf("caching dependencies of chart %q failed: %s", config.Name, err)
+		}
+	}
+	return nil
+}
+
+// readDependencies returns dependencies of Chart.yaml. Chart package or dir without Chart.yaml has none.
+func readDependencies(chartDir string) ([]chartDependency, error) {
+	content, err := os.ReadFile(filepath.Join(chartDir, "Chart.yaml"))
+	if err != nil {
+		return nil, nil
+	}
+	var chart struct {
+		Dependencies []chartDependency `yaml:"dependencies"`
+	}
+	if err := yaml.Unmarshal(content, &chart); err != nil {
+		return nil, fmt.Errorf("parsing Chart.yaml of chart %q failed error: %s", chartDir, err)
+	}
+	return chart.Dependencies, nil
+}
+
+// hasMissingDependencies returns true if any dependency is neither vendored nor packaged in charts dir
+func hasMissingDependencies(chartDir string, dependencies []chartDependency) bool {
+	chartsDir := filepath.Join(chartDir, "charts")
+	for _, dependency := range dependencies {
+		if info, err := os.Stat(filepath.Join(chartsDir, dependency.Name)); err == nil && info.IsDir() {
+			continue
+		}
+		if !hasDependencyPackage(chartsDir, dependency) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasDependencyPackage returns true if charts dir contains package of dependency.
+// Package of pinned version is matched by file name. Otherwise packages named like the dependency
+// are matched by chart name so other chart sharing the name prefix does not satisfy the dependency.
+func hasDependencyPackage(chartsDir string, dependency chartDependency) bool {
+	if isPinnedVersion(dependency.Version) {
+		_, err := os.Stat(filepath.Join(chartsDir, dependency.Name+"-"+dependency.Version+".tgz"))
+		return err == nil
+	}
+	packages, _ := filepath.Glob(filepath.Join(chartsDir, dependency.Name+"-*.tgz"))
+	for _, pkg := range packages {
+		content, err := readChartFile(pkg, "Chart.yaml")
+		if err != nil || content == nil {
+			continue
+		}
+		var chart struct {
+			Name string `yaml:"name"`
+		}
+		if err := yaml.Unmarshal(content, &chart); err == nil && chart.Name == dependency.Name {
+			return true
+		}
+	}
+	return false
+}
+
+// isPinnedVersion returns true for exact version which is not a semver constraint
+func isPinnedVersion(version string) bool {
+	return version != "" && !strings.ContainsAny(version, "^~<>=*xX|, ")
+}
+
+// addDependencyRepos adds repos of dependencies to isolated helm env required by helm dependency build.
+// Repositories declared in helm.repositories give credentials and TLS settings to dependencies referencing
+// them by @name, alias:name or url. Oci registries are logged in like oci charts.
+func addDependencyRepos(config *types.HelmChart, env *helmEnv, dependencies []chartDependency, tempDir string) error {
+	added := map[string]bool{}
+	for i, dependency := range dependencies {
+		repo := strings.TrimSuffix(dependency.Repository, "/")
+		if repo == "" || strings.HasPrefix(repo, localUrlPrefix) || added[repo] {
+			continue
+		}
+		repoName, dependencyConfig, err := dependencyRepository(config, dependency, i)
+		if err != nil {
+			return err
+		}
+		if added[repoName] {
+			continue
+		}
+		added[repo], added[repoName] = true, true
+		tlsDir := filepath.Join(tempDir, repoName)
+		if err := os.MkdirAll(tlsDir, 0700); err != nil {
+			return err
+		}
+		if err := materializeTlsFiles(dependencyConfig, tlsDir); err != nil {
+			return err
+		}
+		if strings.HasPrefix(dependencyConfig.RepoUrl, "oci://") {
+			if err := authenticate(newOciHelmGenerator(dependencyConfig), env); err != nil {
+				return fmt.Errorf("login to dependency registry %q failed: %s", dependencyConfig.RepoUrl, err)
+			}
+			continue
+		}
+		args := []string{"repo", "add", repoName, dependencyConfig.RepoUrl}
+		credentialsArgs, password := credentialsArgs(dependencyConfig)
+		args = append(args, credentialsArgs...)
+		args = append(args, tlsArgs(dependencyConfig)...)
+		if _, err := env.runWithInput(password, args...); err != nil {
+			return fmt.Errorf("adding dependency repo %q failed: %s", dependencyConfig.RepoUrl, err)
+		}
+	}
+	return nil
+}
+
+// dependencyRepository returns helm repo name and config of dependency repo resolved against declared repositories.
+// Repo referenced like @name or alias:name is added under the name helm dependency build looks for.
+func dependencyRepository(config *types.HelmChart, dependency chartDependency, index int) (string, *types.HelmChart, error) {
+	repo := strings.TrimSuffix(dependency.Repository, "/")
+	repoName := fmt.Sprintf("%s-dependency-%d", repoAlias, index)
+	dependencyConfig := &types.HelmChart{Name: dependency.Name, RepoUrl: repo}
+	if name, found := cutRepositoryRef(repo); found {
+		repoName = name
+		dependencyConfig.RepoUrl = repositoryRefPrefix + name
+	} else if repository := findRepositoryByUrl(config.Repositories, repo); repository != nil {
+		dependencyConfig.RepoUrl = repositoryRefPrefix + repository.Name
+	}
+	if err := resolveRepository(&types.Helm{Repositories: config.Repositories}, dependencyConfig, ""); err != nil {
+		return "", nil, fmt.Errorf("dependency %q of chart %q: %s", dependency.Name, config.Name, err)
+	}
+	return repoName, dependencyConfig, nil
+}
+
+// cutRepositoryRef returns repo name of dependency repository like @name or alias:name
+func cutRepositoryRef(repo string) (string, bool) {
+	if name, found := strings.CutPrefix(repo, repositoryRefPrefix); found {
+		return name, true
+	}
+	return strings.CutPrefix(repo, "alias:")
+}
+
+// dependenciesCacheKey returns cache key from dependency digests locked by krmgen lock. Empty key if not locked.
+func dependenciesCacheKey(lockedDependencies []types.LockedDependency) string {
+	if len(lockedDependencies) == 0 {
+		return ""
+	}
+	hash := sha256.New()
+	hash.Write([]byte("dependencies\n"))
+	for _, dependency := range lockedDependencies {
+		hash.Write([]byte(dependency.File + " " + dependency.Digest + "\n"))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// verifyLockedDependencies checks packages built into charts dir against digests locked by krmgen lock
+func verifyLockedDependencies(config *types.HelmChart, chartsDir string) error {
+	for _, dependency := range config.LockedDependencies {
+		digest, err := fileDigest(filepath.Join(chartsDir, dependency.File))
+		if err != nil {
+			return fmt.Errorf("dependency %q of chart %q locked in %s was not built: %s", dependency.File, config.Name, LockFileName, err)
+		}
+		if digestPrefix+digest != dependency.Digest {
+			return fmt.Errorf("dependency %q of chart %q digest %s%s does not match %s digest %s. Run krmgen lock to update it", dependency.File, config.Name, digestPrefix, digest, LockFileName, dependency.Digest)
+		}
+	}
+	return nil
+}
+
+// getDependencies copies cached dependency packages to charts dir
+func (c *chartCache) getDependencies(key string, chartsDir string) bool {
+	content := c.lookup(key)
+	if content == "" {
+		return false
+	}
+	if err := os.MkdirAll(chartsDir, 0755); err != nil {
+		return false
+	}
+	for _, line := range strings.Split(content, "\n") {
+		digest, fileName, found := strings.Cut(line, " ")
+		if !found || fileName != filepath.Base(fileName) {
+			return false
+		}
+		if err := c.copyVerified(digest, filepath.Join(chartsDir, fileName)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// storeDependencies stores dependency packages of charts dir under the key
+func (c *chartCache) storeDependencies(key string, chartsDir string) error {
+	packages, err := filepath.Glob(filepath.Join(chartsDir, "*.tgz"))
+	if err != nil || len(packages) == 0 {
+		return err
+	}
+	var lines []string
+	for _, pkg := range packages {
+		digest, err := c.storeBlob(pkg)
+		if err != nil {
+			return err
+		}
+		lines = append(lines, digest+" "+filepath.Base(pkg))
+	}
+	if err := c.storeRef(key, strings.Join(lines, "\n")); err != nil {
+		return err
+	}
+	c.evict()
+	return nil
+}
+
+// copyDir copies directory tree with regular files
+func copyDir(srcDir string, dstDir string) error {
+	return filepath.WalkDir(srcDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dstDir, relPath)
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		return copyFile(path, target, info.Mode().Perm())
+	})
+}
+
+func copyFile(srcFile string, dstFile string, perm fs.FileMode) error {
+	source, err := os.Open(srcFile)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	target, err := os.OpenFile(dstFile, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	defer target.Close()
+	_, err = io.Copy(target, source)
+	return err
+}
@@ -0,0 +1,207 @@
+package helm
+
+import (
+	"fmt"
+	types "github.com/librucha/krmgen/internal"
+	cons "github.com/librucha/krmgen/internal/utils"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeHelmDependencyBuild creates helm executable recording calls and packaging redis dependency on build
+func fakeHelmDependencyBuild(t *testing.T) string {
+	binDir := t.TempDir()
+	callsFile := filepath.Join(binDir, "calls")
+	script := fmt.Sprintf(`#!/bin/sh
+echo "$1 $2" >> %q
+if [ "$1" = "dependency" ]; then
+  mkdir -p "$3/charts" && echo redis > "$3/charts/redis-17.0.0.tgz"
+fi
+`, callsFile)
+	helmFile := filepath.Join(binDir, "helm")
+	if err := os.WriteFile(helmFile, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(cons.EnvHelmExecutable, helmFile)
+	return callsFile
+}
+
+func Test_buildDependencies(t *testing.T) {
+	chartYaml := `apiVersion: v2
+name: app
+version: 1.0.0
+dependencies:
+  - name: redis
+    version: 17.0.0
+    repository: https://charts.bitnami.com/bitnami
+  - name: common
+    version: 1.0.0
+    repository: file://../common
+`
+	workDir := t.TempDir()
+	chartDir := filepath.Join(workDir, "charts", "app")
+	_ = os.MkdirAll(filepath.Join(chartDir, "charts", "common"), 0755)
+	_ = os.MkdirAll(filepath.Join(chartDir, "templates"), 0755)
+	_ = os.WriteFile(filepath.Join(chartDir, "Chart.yaml"), []byte(chartYaml), 0644)
+	_ = os.WriteFile(filepath.Join(chartDir, "templates", "cm.yaml"), []byte("kind: ConfigMap\n"), 0644)
+
+	callsFile := fakeHelmDependencyBuild(t)
+	if err := EnableChartCache(t.TempDir(), 0); err != nil {
+		t.Fatal(err)
+	}
+	defer DisableChartCache()
+
+	locked := []types.LockedDependency{{File: "redis-17.0.0.tgz", Digest: sha256Digest([]byte("redis\n"))}}
+	build := func(lockedDependencies []types.LockedDependency) *localHelmGenerator {
+		config := &types.HelmChart{Name: "app", RepoUrl: "./charts/app", ReleaseName: "app", LockedDependencies: lockedDependencies}
+		generator := newLocalHelmGenerator(config, workDir)
+		tempDir := t.TempDir()
+		if err := buildDependencies(generator, testHelmEnv(t), tempDir); err != nil {
+			t.Fatalf("buildDependencies() error = %v", err)
+		}
+		if !strings.HasPrefix(generator.sourceDir(), tempDir) {
+			t.Fatalf("buildDependencies() chart dir %q is not copied to temp dir", generator.sourceDir())
+		}
+		for _, file := range []string{"templates/cm.yaml", "charts/redis-17.0.0.tgz"} {
+			if _, err := os.Stat(filepath.Join(generator.sourceDir(), file)); err != nil {
+				t.Errorf("buildDependencies() chart copy misses %s", file)
+			}
+		}
+		return generator
+	}
+
+	// dependencies not locked by krmgen lock are not cached
+	build(nil)
+	build(nil)
+	calls, _ := os.ReadFile(callsFile)
+	if want := "repo add\ndependency build\nrepo add\ndependency build\n"; string(calls) != want {
+		t.Errorf("buildDependencies() helm calls = %q, want %q", calls, want)
+	}
+	if _, err := os.Stat(filepath.Join(chartDir, "charts", "redis-17.0.0.tgz")); err == nil {
+		t.Errorf("buildDependencies() modified chart in work dir")
+	}
+
+	// dependencies locked by krmgen lock are restored from cache
+	_ = os.Remove(callsFile)
+	build(locked)
+	build(locked)
+	calls, _ = os.ReadFile(callsFile)
+	if want := "repo add\ndependency build\n"; string(calls) != want {
+		t.Errorf("buildDependencies() cached helm calls = %q, want %q", calls, want)
+	}
+
+	// built dependency must match krmgen lock
+	config := &types.HelmChart{Name: "app", RepoUrl: "./charts/app", ReleaseName: "app", LockedDependencies: []types.LockedDependency{{File: "redis-17.0.0.tgz", Digest: sha256Digest([]byte("other\n"))}}}
+	if err := buildDependencies(newLocalHelmGenerator(config, workDir), testHelmEnv(t), t.TempDir()); err == nil {
+		t.Errorf("buildDependencies() expected error for dependency not matching lock")
+	}
+}
+
+func Test_addDependencyRepos(t *testing.T) {
+	binDir := t.TempDir()
+	callsFile := filepath.Join(binDir, "calls")
+	script := fmt.Sprintf(`#!/bin/sh
+echo "$@ stdin=$(cat)" >> %q
+`, callsFile)
+	_ = os.WriteFile(filepath.Join(binDir, "helm"), []byte(script), 0755)
+	t.Setenv(cons.EnvHelmExecutable, filepath.Join(binDir, "helm"))
+	t.Setenv(cons.EnvHelmUsername, "")
+	t.Setenv(cons.EnvHelmPassword, "")
+
+	config := &types.HelmChart{Name: "app", Repositories: []types.HelmRepository{
+		{Name: "bitnami", Url: "https://charts.bitnami.com/bitnami", Username: "bitnami-user", Password: "bitnami-password"},
+		{Name: "internal", Url: "https://charts.example.com", Username: "internal-user", Password: "internal-password", CaFile: "/certs/ca.pem"},
+		{Name: "registry", Url: "oci://registry.example.com/charts", Username: "registry-user", Password: "registry-password"},
+	}}
+	dependencies := []chartDependency{
+		{Name: "redis", Repository: "https://charts.bitnami.com/bitnami/"},
+		{Name: "postgresql", Repository: "https://charts.bitnami.com/bitnami"},
+		{Name: "common", Repository: "@internal"},
+		{Name: "lib", Repository: "alias:internal"},
+		{Name: "queue", Repository: "oci://registry.example.com/charts"},
+		{Name: "public", Repository: "https://public.example.com"},
+		{Name: "local", Repository: "file://../local"},
+	}
+	if err := addDependencyRepos(config, testHelmEnv(t), dependencies, t.TempDir()); err != nil {
+		t.Fatalf("addDependencyRepos() error = %v", err)
+	}
+	calls, _ := os.ReadFile(callsFile)
+	want := `repo add krmgen-dependency-0 https://charts.bitnami.com/bitnami --username bitnami-user --password-stdin stdin=bitnami-password
+repo add internal https://charts.example.com --username internal-user --password-stdin --ca-file /certs/ca.pem stdin=internal-password
+registry login registry.example.com --username registry-user --password-stdin stdin=registry-password
+repo add krmgen-dependency-5 https://public.example.com stdin=
+`
+	if string(calls) != want {
+		t.Errorf("addDependencyRepos() helm calls = %q, want %q", calls, want)
+	}
+
+	undeclared := []chartDependency{{Name: "redis", Repository: "@missing"}}
+	if err := addDependencyRepos(config, testHelmEnv(t), undeclared, t.TempDir()); err == nil {
+		t.Errorf("addDependencyRepos() expected error for undeclared repository")
+	}
+}
+
+func Test_buildDependencies_satisfied(t *testing.T) {
+	chartDir := t.TempDir()
+	_ = os.MkdirAll(filepath.Join(chartDir, "charts"), 0755)
+	_ = os.WriteFile(filepath.Join(chartDir, "Chart.yaml"), []byte("name: app\ndependencies:\n  - name: redis\n    version: 17.0.0\n"), 0644)
+	_ = os.WriteFile(filepath.Join(chartDir, "charts", "redis-17.0.0.tgz"), []byte("redis"), 0644)
+	callsFile := fakeHelmDependencyBuild(t)
+
+	generator := newLocalHelmGenerator(&types.HelmChart{Name: "app", RepoUrl: "file://" + chartDir, ReleaseName: "app"}, "")
+	if err := buildDependencies(generator, testHelmEnv(t), t.TempDir()); err != nil {
+		t.Fatalf("buildDependencies() error = %v", err)
+	}
+	if generator.sourceDir() != chartDir {
+		t.Errorf("buildDependencies() chart dir = %q, want %q", generator.sourceDir(), chartDir)
+	}
+	if _, err := os.Stat(callsFile); err == nil {
+		t.Errorf("buildDependencies() called helm for satisfied dependencies")
+	}
+}
+
+func Test_hasMissingDependencies(t *testing.T) {
+	chartDir := t.TempDir()
+	chartsDir := filepath.Join(chartDir, "charts")
+	_ = os.MkdirAll(chartsDir, 0755)
+	_ = os.WriteFile(filepath.Join(chartsDir, "redis-cluster-9.0.0.tgz"), chartPackage(t, "redis-cluster", "9.0.0"), 0644)
+	_ = os.WriteFile(filepath.Join(chartsDir, "postgresql-12.1.0.tgz"), chartPackage(t, "postgresql", "12.1.0"), 0644)
+	tests := []struct {
+		name         string
+		dependencies []chartDependency
+		want         bool
+	}{
+		{
+			name:         "pinned version packaged",
+			dependencies: []chartDependency{{Name: "redis-cluster", Version: "9.0.0"}},
+		},
+		{
+			name:         "pinned version matched by chart sharing name prefix",
+			dependencies: []chartDependency{{Name: "redis", Version: "9.0.0"}},
+			want:         true,
+		},
+		{
+			name:         "pinned version not packaged",
+			dependencies: []chartDependency{{Name: "postgresql", Version: "12.2.0"}},
+			want:         true,
+		},
+		{
+			name:         "version range packaged",
+			dependencies: []chartDependency{{Name: "postgresql", Version: "~12.1"}},
+		},
+		{
+			name:         "version range matched by chart sharing name prefix",
+			dependencies: []chartDependency{{Name: "redis", Version: ">=9.0.0"}},
+			want:         true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hasMissingDependencies(chartDir, tt.dependencies); got != tt.want {
+				t.Errorf("hasMissingDependencies() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
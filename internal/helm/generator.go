@@ -17,9 +17,8 @@ type idProvider interface {
 }
 
 type authenticator interface {
-	// authenticate to specific helm remote
-	login()
-	addCredentials([]string) []string
+	// login authenticates to specific helm remote within isolated helm env
+	login(env *helmEnv) error
 }
 
 type configProvider interface {
@@ -33,37 +32,70 @@ type generator interface {
 	addRepoArgs([]string) []string
 }
 
-func newGenerator(config *types.HelmChart) (generator, error) {
+// fetcher is implemented by generators which download chart sources before templating
+type fetcher interface {
+	fetch(tempDir string) error
+}
+
+func newGenerator(config *types.HelmChart, workDir string) (generator, error) {
 	normUrl := strings.ToLower(config.RepoUrl)
+	if strings.HasPrefix(normUrl, gitUrlPrefix) {
+		return newGitHelmGenerator(config), nil
+	}
+	if strings.HasPrefix(normUrl, "http") && isTarballUrl(normUrl) {
+		return newTarballHelmGenerator(config), nil
+	}
 	if strings.HasPrefix(normUrl, "oci") {
 		return newOciHelmGenerator(config), nil
 	}
 	if strings.HasPrefix(normUrl, "http") {
 		return newRepoHelmGenerator(config), nil
 	}
+	if isLocalPath(config.RepoUrl) {
+		return newLocalHelmGenerator(config, workDir), nil
+	}
 	return nil, fmt.Errorf("helm repo %q is not supported by any generator", config.RepoUrl)
 }
 
+// authenticate logs in to chart remote with configured credentials or Azure identity for ACR
+func authenticate(generator generator, env *helmEnv) error {
+	if err := acrCredentials(generator); err != nil {
+		return err
+	}
+	if !credentialsProvided(generator.getConfig()) {
+		return nil
+	}
+	return generator.login(env)
+}
+
 // credentialsProvided returns true if username and password are provided some way
 func credentialsProvided(config *types.HelmChart) bool {
-	return len(credentialsArgs(config)) > 0
+	username, password := credentials(config)
+	return username != "" || password != ""
 }
 
-func credentialsArgs(config *types.HelmChart) []string {
+// credentialsArgs returns helm login args and password passed by standard input so it is not visible in process list
+func credentialsArgs(config *types.HelmChart) ([]string, string) {
 	var args []string
+	username, password := credentials(config)
+	if username != "" {
+		args = append(args, "--username", username)
+	}
+	if password != "" {
+		args = append(args, "--password-stdin")
+	}
+	return args, password
+}
+
+// credentials returns username and password from config with fallback to env
+func credentials(config *types.HelmChart) (string, string) {
 	username := config.Username
 	if username == "" {
 		username = os.Getenv(cons.EnvHelmUsername)
 	}
-	if username != "" {
-		args = append(args, "--username", username)
-	}
 	password := config.Password
 	if password == "" {
 		password = os.Getenv(cons.EnvHelmPassword)
 	}
-	if password != "" {
-		args = append(args, "--password", password)
-	}
-	return args
+	return username, password
 }
@@ -11,6 +11,10 @@ import (
 func Test_newGenerator(t *testing.T) {
 	repoConfig := &types.HelmChart{RepoUrl: "https://grafana.github.io/helm-charts"}
 	ociConfig := &types.HelmChart{RepoUrl: "oci://github.com"}
+	localConfig := &types.HelmChart{RepoUrl: "./charts/app"}
+	fileConfig := &types.HelmChart{RepoUrl: "file://charts/app"}
+	gitConfig := &types.HelmChart{RepoUrl: "git::https://github.com/librucha/charts.git//charts/app?ref=v1.0.0"}
+	tarballConfig := &types.HelmChart{RepoUrl: "https://charts.example.com/app-1.0.0.tgz"}
 	type args struct {
 		config *types.HelmChart
 	}
@@ -34,6 +38,34 @@ func Test_newGenerator(t *testing.T) {
 			want:    newOciHelmGenerator(ociConfig),
 			wantErr: false,
 		},
+		{
+			name: "local generator",
+			args: args{
+				config: localConfig},
+			want:    newLocalHelmGenerator(localConfig, "/work"),
+			wantErr: false,
+		},
+		{
+			name: "local file url generator",
+			args: args{
+				config: fileConfig},
+			want:    newLocalHelmGenerator(fileConfig, "/work"),
+			wantErr: false,
+		},
+		{
+			name: "git generator",
+			args: args{
+				config: gitConfig},
+			want:    newGitHelmGenerator(gitConfig),
+			wantErr: false,
+		},
+		{
+			name: "tarball generator",
+			args: args{
+				config: tarballConfig},
+			want:    newTarballHelmGenerator(tarballConfig),
+			wantErr: false,
+		},
 		{
 			name: "unknown generator",
 			args: args{
@@ -43,7 +75,7 @@ func Test_newGenerator(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := newGenerator(tt.args.config)
+			got, err := newGenerator(tt.args.config, "/work")
 			if (err != nil) != tt.wantErr {
 				t.Errorf("newGenerator() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -162,10 +194,11 @@ func Test_credentialsArgs(t *testing.T) {
 		config *types.HelmChart
 	}
 	tests := []struct {
-		name string
-		args args
-		env  map[string]string
-		want []string
+		name      string
+		args      args
+		env       map[string]string
+		want      []string
+		wantStdIn string
 	}{
 		{
 			name: "provided both inline",
@@ -174,7 +207,8 @@ func Test_credentialsArgs(t *testing.T) {
 					Username: "username",
 					Password: "password",
 				}},
-			want: []string{"--username", "username", "--password", "password"},
+			want:      []string{"--username", "username", "--password-stdin"},
+			wantStdIn: "password",
 		},
 		{
 			name: "provided both in ENV",
@@ -188,7 +222,8 @@ func Test_credentialsArgs(t *testing.T) {
 				cons.EnvHelmUsername: "username",
 				cons.EnvHelmPassword: "password",
 			},
-			want: []string{"--username", "username", "--password", "password"},
+			want:      []string{"--username", "username", "--password-stdin"},
+			wantStdIn: "password",
 		},
 		{
 			name: "empty username",
@@ -197,7 +232,8 @@ func Test_credentialsArgs(t *testing.T) {
 					Username: "",
 					Password: "password",
 				}},
-			want: []string{"--password", "password"},
+			want:      []string{"--password-stdin"},
+			wantStdIn: "password",
 		},
 		{
 			name: "provided only username in ENV",
@@ -223,7 +259,8 @@ func Test_credentialsArgs(t *testing.T) {
 			env: map[string]string{
 				cons.EnvHelmPassword: "password",
 			},
-			want: []string{"--password", "password"},
+			want:      []string{"--password-stdin"},
+			wantStdIn: "password",
 		},
 		{
 			name: "empty password",
@@ -249,9 +286,13 @@ func Test_credentialsArgs(t *testing.T) {
 			for k, v := range tt.env {
 				_ = os.Setenv(k, v)
 			}
-			if got := credentialsArgs(tt.args.config); !reflect.DeepEqual(got, tt.want) {
+			got, gotStdIn := credentialsArgs(tt.args.config)
+			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("credentialsArgs() = %v, want %v", got, tt.want)
 			}
+			if gotStdIn != tt.wantStdIn {
+				t.Errorf("credentialsArgs() stdIn = %v, want %v", gotStdIn, tt.wantStdIn)
+			}
 			for k, _ := range tt.env {
 				_ = os.Unsetenv(k)
 			}
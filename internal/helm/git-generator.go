@@ -0,0 +1,117 @@
+package helm
+
+import (
+	"fmt"
+	types "github.com/librucha/krmgen/internal"
+	"github.com/librucha/krmgen/internal/tool"
+	cons "github.com/librucha/krmgen/internal/utils"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const gitUrlPrefix = "git::"
+
+// gitHelmGenerator renders chart from git repository declared like git::https://host/repo.git//charts/app?ref=v1.0.0
+type gitHelmGenerator struct {
+	config   *types.HelmChart
+	chartDir string
+}
+
+func (g *gitHelmGenerator) getConfig() *types.HelmChart {
+	return g.config
+}
+
+func (g *gitHelmGenerator) chartId() string {
+	return g.config.RepoUrl
+}
+
+func (g *gitHelmGenerator) chartIdShort() string {
+	repo, subPath, _ := parseGitUrl(g.config.RepoUrl)
+	if subPath != "" {
+		return path.Base(subPath)
+	}
+	return strings.TrimSuffix(path.Base(repo), ".git")
+}
+
+func (g *gitHelmGenerator) login(*helmEnv) error {
+	// git authentication is up to git configuration
+	return nil
+}
+
+func (g *gitHelmGenerator) addRepoArgs(in []string) []string {
+	return append(in, g.chartDir)
+}
+
+func (g *gitHelmGenerator) sourceDir() string {
+	return g.chartDir
+}
+
+func (g *gitHelmGenerator) useDir(chartDir string) {
+	g.chartDir = chartDir
+}
+
+// fetch clones the repository at given ref into temp dir
+func (g *gitHelmGenerator) fetch(tempDir string) error {
+	repo, subPath, ref := parseGitUrl(g.config.RepoUrl)
+	if ref == "" {
+		ref = "HEAD"
+	}
+	cloneDir := filepath.Join(tempDir, "git-"+g.chartIdShort())
+	commands := [][]string{
+		{"init", "--quiet", cloneDir},
+		{"-C", cloneDir, "remote", "add", "origin", repo},
+		{"-C", cloneDir, "fetch", "--quiet", "--depth", "1", "origin", ref},
+		{"-C", cloneDir, "checkout", "--quiet", "FETCH_HEAD"},
+	}
+	for _, args := range commands {
+		_, stdErr, err := tool.RunCommand(gitExecutable(), args...)
+		if err != nil {
+			return fmt.Errorf("run command %q finished with error %v. Error output %v", gitExecutable(), err, stdErr)
+		}
+	}
+	g.chartDir = filepath.Join(cloneDir, filepath.FromSlash(subPath))
+	if _, err := os.Stat(g.chartDir); err != nil {
+		return fmt.Errorf("chart path %q not found in git repo %q", subPath, repo)
+	}
+	return nil
+}
+
+// parseGitUrl returns repository url, sub path and ref of git chart url
+func parseGitUrl(gitUrl string) (string, string, string) {
+	repo := gitUrl[len(gitUrlPrefix):]
+	var ref string
+	if i := strings.LastIndex(repo, "?ref="); i >= 0 {
+		repo, ref = repo[:i], repo[i+len("?ref="):]
+	}
+	schemeEnd := strings.Index(repo, "://")
+	if schemeEnd >= 0 {
+		schemeEnd += len("://")
+	} else {
+		schemeEnd = 0
+	}
+	var subPath string
+	if i := strings.Index(repo[schemeEnd:], "//"); i >= 0 {
+		repo, subPath = repo[:schemeEnd+i], strings.Trim(repo[schemeEnd+i+2:], "/")
+	}
+	return repo, subPath, ref
+}
+
+func gitExecutable() string {
+	git, found := os.LookupEnv(cons.EnvGitExecutable)
+	if !found {
+		path, err := exec.LookPath("git")
+		if err != nil {
+			log.Fatalf("git executable not found in OS")
+		}
+		return path
+	}
+	return git
+}
+
+func newGitHelmGenerator(config *types.HelmChart) *gitHelmGenerator {
+	return &gitHelmGenerator{config: config}
+}
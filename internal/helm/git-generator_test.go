@@ -0,0 +1,108 @@
+package helm
+
+import (
+	types "github.com/librucha/krmgen/internal"
+	"github.com/librucha/krmgen/internal/tool"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func Test_parseGitUrl(t *testing.T) {
+	tests := []struct {
+		name   string
+		gitUrl string
+		want   []string
+	}{
+		{
+			name:   "https with sub path and ref",
+			gitUrl: "git::https://github.com/librucha/charts.git//charts/app?ref=v1.0.0",
+			want:   []string{"https://github.com/librucha/charts.git", "charts/app", "v1.0.0"},
+		},
+		{
+			name:   "https without sub path",
+			gitUrl: "git::https://github.com/librucha/app-chart.git?ref=main",
+			want:   []string{"https://github.com/librucha/app-chart.git", "", "main"},
+		},
+		{
+			name:   "ssh without ref",
+			gitUrl: "git::git@github.com:librucha/charts.git//charts/app",
+			want:   []string{"git@github.com:librucha/charts.git", "charts/app", ""},
+		},
+		{
+			name:   "file url",
+			gitUrl: "git::file:///tmp/repo//charts/app/",
+			want:   []string{"file:///tmp/repo", "charts/app", ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo, subPath, ref := parseGitUrl(tt.gitUrl)
+			if got := []string{repo, subPath, ref}; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseGitUrl() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_gitHelmGenerator_fetch(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git executable not found")
+	}
+	repoDir := t.TempDir()
+	_ = os.MkdirAll(filepath.Join(repoDir, "charts", "app"), 0755)
+	_ = os.WriteFile(filepath.Join(repoDir, "charts", "app", "Chart.yaml"), []byte("name: app\nversion: 1.0.0\n"), 0644)
+	for _, args := range [][]string{
+		{"-C", repoDir, "init", "--quiet"},
+		{"-C", repoDir, "add", "."},
+		{"-C", repoDir, "-c", "user.name=krmgen", "-c", "user.email=krmgen@example.com", "commit", "--quiet", "-m", "chart"},
+		{"-C", repoDir, "tag", "v1.0.0"},
+	} {
+		if _, stdErr, err := tool.RunCommand("git", args...); err != nil {
+			t.Fatalf("git %v failed: %v %s", args, err, stdErr)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		repoUrl string
+		wantErr bool
+	}{
+		{
+			name:    "tag ref",
+			repoUrl: "git::file://" + repoDir + "//charts/app?ref=v1.0.0",
+		},
+		{
+			name:    "default branch",
+			repoUrl: "git::file://" + repoDir + "//charts/app",
+		},
+		{
+			name:    "missing sub path",
+			repoUrl: "git::file://" + repoDir + "//charts/missing",
+			wantErr: true,
+		},
+		{
+			name:    "missing ref",
+			repoUrl: "git::file://" + repoDir + "//charts/app?ref=v9.9.9",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newGitHelmGenerator(&types.HelmChart{RepoUrl: tt.repoUrl})
+			err := g.fetch(t.TempDir())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("fetch() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			if _, err := os.Stat(filepath.Join(g.addRepoArgs(nil)[0], "Chart.yaml")); err != nil {
+				t.Errorf("fetch() chart not found in %v", g.addRepoArgs(nil))
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package helm
+
+import (
+	"fmt"
+	"github.com/librucha/krmgen/internal/tool"
+	"os"
+	"path/filepath"
+)
+
+const envHelmConfigHome = "HELM_CONFIG_HOME"
+const envHelmCacheHome = "HELM_CACHE_HOME"
+const envHelmRegistryConfig = "HELM_REGISTRY_CONFIG"
+const envHelmRepositoryConfig = "HELM_REPOSITORY_CONFIG"
+const envHelmRepositoryCache = "HELM_REPOSITORY_CACHE"
+
+// helmEnv isolates helm registry logins and repositories of single chart render
+// so concurrent renders do not share credentials. It lives in render temp dir and is removed with it.
+type helmEnv struct {
+	home string
+}
+
+// newHelmEnv prepares isolated helm config in temp dir. Registry config of the user is copied
+// so registries logged in before keep working without being modified.
+func newHelmEnv(tempDir string) (*helmEnv, error) {
+	e := &helmEnv{home: filepath.Join(tempDir, "helm-home")}
+	if err := os.MkdirAll(filepath.Dir(e.registryConfig()), 0700); err != nil {
+		return nil, err
+	}
+	if content, err := os.ReadFile(userRegistryConfig()); err == nil {
+		if err := os.WriteFile(e.registryConfig(), content, 0600); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// run runs helm in isolated env and returns its output
+func (e *helmEnv) run(args ...string) (string, error) {
+	return e.runWithInput("", args...)
+}
+
+// runWithInput runs helm in isolated env with given standard input
+func (e *helmEnv) runWithInput(stdIn string, args ...string) (string, error) {
+	stdOut, stdErr, err := tool.RunCommandWithEnv(e.environ(), stdIn, helmExecutable(), args...)
+	if err != nil {
+		return "", fmt.Errorf("run command %q finished with error %v. Error output %v", helmExecutable(), err, stdErr)
+	}
+	return stdOut, nil
+}
+
+func (e *helmEnv) environ() []string {
+	return []string{
+		envHelmConfigHome + "=" + e.configHome(),
+		envHelmCacheHome + "=" + filepath.Join(e.home, "cache"),
+		envHelmRegistryConfig + "=" + e.registryConfig(),
+		envHelmRepositoryConfig + "=" + filepath.Join(e.configHome(), "repositories.yaml"),
+		envHelmRepositoryCache + "=" + filepath.Join(e.home, "cache", "repository"),
+	}
+}
+
+func (e *helmEnv) configHome() string {
+	return filepath.Join(e.home, "config")
+}
+
+func (e *helmEnv) registryConfig() string {
+	return filepath.Join(e.configHome(), "registry", "config.json")
+}
+
+// userRegistryConfig returns registry config path used by helm outside krmgen
+func userRegistryConfig() string {
+	if registryConfig := os.Getenv(envHelmRegistryConfig); registryConfig != "" {
+		return registryConfig
+	}
+	if configHome := os.Getenv(envHelmConfigHome); configHome != "" {
+		return filepath.Join(configHome, "registry", "config.json")
+	}
+	if configHome := os.Getenv("XDG_CONFIG_HOME"); configHome != "" {
+		return filepath.Join(configHome, "helm", "registry", "config.json")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "helm", "registry", "config.json")
+}
@@ -0,0 +1,96 @@
+package helm
+
+import (
+	"fmt"
+	types "github.com/librucha/krmgen/internal"
+	cons "github.com/librucha/krmgen/internal/utils"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeHelmRecorder creates helm executable recording args, stdin and helm env of every call
+func fakeHelmRecorder(t *testing.T) string {
+	binDir := t.TempDir()
+	recordFile := filepath.Join(binDir, "record")
+	script := fmt.Sprintf(`#!/bin/sh
+{
+  echo "args: $*"
+  echo "stdin: $(cat)"
+  echo "registry: $HELM_REGISTRY_CONFIG"
+  echo "repositories: $HELM_REPOSITORY_CONFIG"
+} >> %q
+`, recordFile)
+	helmFile := filepath.Join(binDir, "helm")
+	if err := os.WriteFile(helmFile, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(cons.EnvHelmExecutable, helmFile)
+	return recordFile
+}
+
+func Test_login(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   *types.HelmChart
+		wantArgs string
+	}{
+		{
+			name:     "oci registry",
+			config:   &types.HelmChart{Name: "app", RepoUrl: "oci://registry.example.com/charts", Username: "user", Password: "s3cr3t"},
+			wantArgs: "args: registry login registry.example.com --username user --password-stdin",
+		},
+		{
+			name:     "helm repo",
+			config:   &types.HelmChart{Name: "app", RepoUrl: "https://charts.example.com", Username: "user", Password: "s3cr3t"},
+			wantArgs: "args: repo add krmgen https://charts.example.com --username user --password-stdin",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recordFile := fakeHelmRecorder(t)
+			tempDir := t.TempDir()
+			env, err := newHelmEnv(tempDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			generator, _ := newGenerator(tt.config, "/work")
+			if err := generator.login(env); err != nil {
+				t.Fatalf("login() error = %v", err)
+			}
+			content, _ := os.ReadFile(recordFile)
+			record := strings.Split(strings.TrimSpace(string(content)), "\n")
+			if record[0] != tt.wantArgs {
+				t.Errorf("login() %s, want %s", record[0], tt.wantArgs)
+			}
+			if record[1] != "stdin: s3cr3t" {
+				t.Errorf("login() %s, want password on stdin", record[1])
+			}
+			for _, line := range record[2:] {
+				_, path, _ := strings.Cut(line, ": ")
+				if !strings.HasPrefix(path, tempDir) {
+					t.Errorf("login() %s is not isolated in %s", line, tempDir)
+				}
+			}
+		})
+	}
+}
+
+func Test_newHelmEnv(t *testing.T) {
+	userConfig := filepath.Join(t.TempDir(), "config.json")
+	_ = os.WriteFile(userConfig, []byte(`{"auths":{}}`), 0600)
+	t.Setenv(envHelmRegistryConfig, userConfig)
+
+	env, err := newHelmEnv(t.TempDir())
+	if err != nil {
+		t.Fatalf("newHelmEnv() error = %v", err)
+	}
+	content, err := os.ReadFile(env.registryConfig())
+	if err != nil || string(content) != `{"auths":{}}` {
+		t.Errorf("newHelmEnv() registry config = %q, %v, want copy of user registry config", content, err)
+	}
+	if env.registryConfig() == userConfig {
+		t.Errorf("newHelmEnv() uses user registry config")
+	}
+}
@@ -0,0 +1,106 @@
+package helm
+
+import (
+	"fmt"
+	"github.com/librucha/krmgen/internal/manifest"
+	"strings"
+)
+
+const hooksKeep = "keep"
+const hooksDrop = "drop"
+const hooksConvertToArgocd = "convert-to-argocd"
+
+const helmHookAnnotation = "helm.sh/hook"
+const helmHookWeightAnnotation = "helm.sh/hook-weight"
+const helmHookDeletePolicyAnnotation = "helm.sh/hook-delete-policy"
+
+const argocdHookAnnotation = "argocd.argoproj.io/hook"
+const argocdSyncWaveAnnotation = "argocd.argoproj.io/sync-wave"
+const argocdHookDeletePolicyAnnotation = "argocd.argoproj.io/hook-delete-policy"
+
+// argocdHookPhases maps helm hooks to ArgoCD hook phases. Hooks not listed have no ArgoCD equivalent.
+var argocdHookPhases = map[string]string{
+	"pre-install":  "PreSync",
+	"pre-upgrade":  "PreSync",
+	"post-install": "PostSync",
+	"post-upgrade": "PostSync",
+}
+
+var argocdHookDeletePolicies = map[string]string{
+	"before-hook-creation": "BeforeHookCreation",
+	"hook-succeeded":       "HookSucceeded",
+	"hook-failed":          "HookFailed",
+}
+
+// processHooks keeps, drops or converts helm hook resources of helm output to ArgoCD hooks
+func processHooks(output string, mode string) (string, error) {
+	switch mode {
+	case "", hooksKeep:
+		return output, nil
+	case hooksDrop, hooksConvertToArgocd:
+	default:
+		return "", fmt.Errorf("unknown hooks mode %q expected %s, %s or %s", mode, hooksKeep, hooksDrop, hooksConvertToArgocd)
+	}
+	resources, err := manifest.Parse(output)
+	if err != nil {
+		return "", err
+	}
+	var kept []map[string]any
+	for _, resource := range resources {
+		hook, isHook := manifest.Annotations(resource)[helmHookAnnotation]
+		if !isHook {
+			kept = append(kept, resource)
+			continue
+		}
+		if mode == hooksConvertToArgocd && convertHook(resource, hook) {
+			kept = append(kept, resource)
+		}
+	}
+	return manifest.Format(kept)
+}
+
+// convertHook replaces helm hook annotations by ArgoCD ones. Returns false if the hook has no ArgoCD phase
+// like test, delete or rollback hooks.
+func convertHook(resource map[string]any, hook string) bool {
+	annotations := manifest.Annotations(resource)
+	var phases []string
+	for _, helmHook := range strings.Split(hook, ",") {
+		phase, found := argocdHookPhases[strings.TrimSpace(helmHook)]
+		if found && !contains(phases, phase) {
+			phases = append(phases, phase)
+		}
+	}
+	if len(phases) == 0 {
+		return false
+	}
+	manifest.SetAnnotation(resource, argocdHookAnnotation, strings.Join(phases, ","))
+	if weight, found := annotations[helmHookWeightAnnotation]; found {
+		if _, waveFound := annotations[argocdSyncWaveAnnotation]; !waveFound {
+			manifest.SetAnnotation(resource, argocdSyncWaveAnnotation, weight)
+		}
+	}
+	if deletePolicy, found := annotations[helmHookDeletePolicyAnnotation]; found {
+		var policies []string
+		for _, helmPolicy := range strings.Split(deletePolicy, ",") {
+			if policy, found := argocdHookDeletePolicies[strings.TrimSpace(helmPolicy)]; found {
+				policies = append(policies, policy)
+			}
+		}
+		if len(policies) > 0 {
+			manifest.SetAnnotation(resource, argocdHookDeletePolicyAnnotation, strings.Join(policies, ","))
+		}
+	}
+	for _, key := range []string{helmHookAnnotation, helmHookWeightAnnotation, helmHookDeletePolicyAnnotation} {
+		manifest.RemoveAnnotation(resource, key)
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package helm
+
+import (
+	"testing"
+)
+
+func Test_processHooks(t *testing.T) {
+	output := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+  annotations:
+    helm.sh/hook: pre-install,pre-upgrade
+    helm.sh/hook-weight: "-5"
+    helm.sh/hook-delete-policy: before-hook-creation,hook-succeeded
+    team: backend
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: app-test
+  annotations:
+    helm.sh/hook: test
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: cleanup
+  annotations:
+    helm.sh/hook: pre-delete
+`
+	tests := []struct {
+		name    string
+		mode    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "keep by default",
+			mode: "",
+			want: output,
+		},
+		{
+			name: "drop",
+			mode: "drop",
+			want: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+`,
+		},
+		{
+			name: "convert to argocd",
+			mode: "convert-to-argocd",
+			want: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  annotations:
+    argocd.argoproj.io/hook: PreSync
+    argocd.argoproj.io/hook-delete-policy: BeforeHookCreation,HookSucceeded
+    argocd.argoproj.io/sync-wave: "-5"
+    team: backend
+  name: migrate
+`,
+		},
+		{
+			name:    "unknown mode",
+			mode:    "skip",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := processHooks(output, tt.mode)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("processHooks() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("processHooks() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package helm
+
+import (
+	types "github.com/librucha/krmgen/internal"
+	"path/filepath"
+	"strings"
+)
+
+const localUrlPrefix = "file://"
+
+type localHelmGenerator struct {
+	config  *types.HelmChart
+	workDir string
+	// chartDir is a copy of the chart in temp dir used when dependencies are built
+	chartDir string
+}
+
+func (g *localHelmGenerator) getConfig() *types.HelmChart {
+	return g.config
+}
+
+func (g *localHelmGenerator) chartId() string {
+	chartPath := strings.TrimPrefix(g.config.RepoUrl, localUrlPrefix)
+	if filepath.IsAbs(chartPath) {
+		return filepath.Clean(chartPath)
+	}
+	return filepath.Join(g.workDir, chartPath)
+}
+
+func (g *localHelmGenerator) chartIdShort() string {
+	return filepath.Base(g.chartId())
+}
+
+func (g *localHelmGenerator) login(*helmEnv) error {
+	// local chart needs no login
+	return nil
+}
+
+func (g *localHelmGenerator) addRepoArgs(in []string) []string {
+	return append(in, g.sourceDir())
+}
+
+func (g *localHelmGenerator) sourceDir() string {
+	if g.chartDir != "" {
+		return g.chartDir
+	}
+	return g.chartId()
+}
+
+func (g *localHelmGenerator) useDir(chartDir string) {
+	g.chartDir = chartDir
+}
+
+// isLocalPath returns true for absolute chart directories and directories relative to work dir
+func isLocalPath(repoUrl string) bool {
+	return strings.HasPrefix(repoUrl, localUrlPrefix) ||
+		filepath.IsAbs(repoUrl) ||
+		repoUrl == "." ||
+		strings.HasPrefix(repoUrl, "./") ||
+		strings.HasPrefix(repoUrl, "../")
+}
+
+func newLocalHelmGenerator(config *types.HelmChart, workDir string) *localHelmGenerator {
+	return &localHelmGenerator{config: config, workDir: workDir}
+}
@@ -0,0 +1,49 @@
+package helm
+
+import (
+	types "github.com/librucha/krmgen/internal"
+	"reflect"
+	"testing"
+)
+
+func Test_localHelmGenerator_addRepoArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		repoUrl string
+		want    []string
+	}{
+		{
+			name:    "relative path",
+			repoUrl: "./charts/app",
+			want:    []string{"template", "/work/charts/app"},
+		},
+		{
+			name:    "parent path",
+			repoUrl: "../charts/app",
+			want:    []string{"template", "/charts/app"},
+		},
+		{
+			name:    "file url",
+			repoUrl: "file://charts/app",
+			want:    []string{"template", "/work/charts/app"},
+		},
+		{
+			name:    "absolute path",
+			repoUrl: "/charts/app",
+			want:    []string{"template", "/charts/app"},
+		},
+		{
+			name:    "absolute file url",
+			repoUrl: "file:///charts/app/",
+			want:    []string{"template", "/charts/app"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newLocalHelmGenerator(&types.HelmChart{RepoUrl: tt.repoUrl}, "/work")
+			if got := g.addRepoArgs([]string{"template"}); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("addRepoArgs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package helm
+
+import (
+	"errors"
+	"fmt"
+	types "github.com/librucha/krmgen/internal"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const LockFileName = "krmgen.lock"
+
+const lockApiVersion = "krmgen.config.librucha.com/v1alpha1"
+const lockKind = "KrmGenLock"
+
+const digestPrefix = "sha256:"
+
+// ReadLock reads lock file of work dir. Returns nil lock if the file does not exist.
+func ReadLock(workDir string) (*types.Lock, error) {
+	lockFile := filepath.Join(workDir, LockFileName)
+	content, err := os.ReadFile(lockFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var lock types.Lock
+	if err := yaml.Unmarshal(content, &lock); err != nil {
+		return nil, fmt.Errorf("parsing lock file %q failed error: %s", lockFile, err)
+	}
+	return &lock, nil
+}
+
+// WriteLock writes lock file to work dir
+func WriteLock(workDir string, lock *types.Lock) error {
+	content, err := yaml.Marshal(lock)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(workDir, LockFileName), content, 0644)
+}
+
+// LockCharts resolves version constraints of charts pulled by helm to exact versions and digests.
+// Local and git charts are locked with digests of dependencies built by helm. Tarball urls are not locked.
+func LockCharts(configs []*types.Config, workDir string) (*types.Lock, error) {
+	lock := &types.Lock{ApiVersion: lockApiVersion, Kind: lockKind}
+	for _, config := range configs {
+		if !config.HasHelm() {
+			continue
+		}
+		for _, helmChartConfig := range *config.Helm.Charts {
+			if err := resolveRepository(config.Helm, &helmChartConfig, workDir); err != nil {
+				return nil, err
+			}
+			if findLockedChart(lock, &helmChartConfig) != nil {
+				continue
+			}
+			lockedChart, err := lockChart(config.Helm, &helmChartConfig, workDir)
+			if err != nil {
+				return nil, err
+			}
+			if lockedChart != nil {
+				lock.Charts = append(lock.Charts, *lockedChart)
+			}
+		}
+	}
+	return lock, nil
+}
+
+func lockChart(helm *types.Helm, helmChartConfig *types.HelmChart, workDir string) (*types.LockedChart, error) {
+	generator, err := newGenerator(helmChartConfig, workDir)
+	if err != nil {
+		return nil, err
+	}
+	puller, ok := generator.(cacheable)
+	_, isSource := generator.(chartSource)
+	if !ok && !isSource {
+		return nil, nil
+	}
+	tempDir, err := os.MkdirTemp(os.TempDir(), "krmgen-lock")
+	if err != nil {
+		return nil, err
+	}
+	defer func(path string) {
+		_ = os.RemoveAll(path)
+	}(tempDir)
+
+	env, err := newHelmEnv(tempDir)
+	if err != nil {
+		return nil, err
+	}
+	if err := materializeTlsFiles(helmChartConfig, tempDir); err != nil {
+		return nil, err
+	}
+	if isSource {
+		resolveDependencyRepositories(helm, helmChartConfig, workDir)
+		return lockDependencies(generator, env, tempDir)
+	}
+	if err := authenticate(generator, env); err != nil {
+		return nil, err
+	}
+	chartFile, err := pullChart(generator, puller, env, tempDir)
+	if err != nil {
+		return nil, err
+	}
+	version, err := packagedChartVersion(chartFile)
+	if err != nil {
+		return nil, err
+	}
+	digest, err := fileDigest(chartFile)
+	if err != nil {
+		return nil, err
+	}
+	return &types.LockedChart{
+		ReleaseName: helmChartConfig.ReleaseName,
+		Name:        helmChartConfig.Name,
+		RepoUrl:     helmChartConfig.RepoUrl,
+		Constraint:  helmChartConfig.Version,
+		Version:     version,
+		Digest:      digestPrefix + digest,
+	}, nil
+}
+
+// lockDependencies builds dependencies of local or git chart and locks digests of packages in its charts dir.
+// Chart without missing dependencies is not locked.
+func lockDependencies(generator generator, env *helmEnv, tempDir string) (*types.LockedChart, error) {
+	if f, ok := generator.(fetcher); ok {
+		if err := f.fetch(tempDir); err != nil {
+			return nil, err
+		}
+	}
+	source := generator.(chartSource)
+	dependencies, err := readDependencies(source.sourceDir())
+	if err != nil {
+		return nil, err
+	}
+	if !hasMissingDependencies(source.sourceDir(), dependencies) {
+		return nil, nil
+	}
+	if err := buildDependencies(generator, env, tempDir); err != nil {
+		return nil, err
+	}
+	packages, err := filepath.Glob(filepath.Join(source.sourceDir(), "charts", "*.tgz"))
+	if err != nil {
+		return nil, err
+	}
+	config := generator.getConfig()
+	lockedChart := &types.LockedChart{
+		ReleaseName: config.ReleaseName,
+		Name:        config.Name,
+		RepoUrl:     config.RepoUrl,
+		Constraint:  config.Version,
+	}
+	for _, pkg := range packages {
+		digest, err := fileDigest(pkg)
+		if err != nil {
+			return nil, err
+		}
+		lockedChart.Dependencies = append(lockedChart.Dependencies, types.LockedDependency{File: filepath.Base(pkg), Digest: digestPrefix + digest})
+	}
+	return lockedChart, nil
+}
+
+// applyLock pins version and digest of chart pulled by helm from the lock.
+// Dependencies of local and git charts are pinned when the chart is locked.
+func applyLock(lock *types.Lock, generator generator) error {
+	if lock == nil {
+		return nil
+	}
+	config := generator.getConfig()
+	if _, ok := generator.(chartSource); ok {
+		if lockedChart := findLockedChart(lock, config); lockedChart != nil {
+			config.LockedDependencies = lockedChart.Dependencies
+		}
+		return nil
+	}
+	if _, ok := generator.(cacheable); !ok {
+		return nil
+	}
+	lockedChart := findLockedChart(lock, config)
+	if lockedChart == nil {
+		return fmt.Errorf("chart %q with release %q and version %q is not in %s. Run krmgen lock to update it", config.Name, config.ReleaseName, config.Version, LockFileName)
+	}
+	config.Version = lockedChart.Version
+	config.Digest = lockedChart.Digest
+	return nil
+}
+
+func findLockedChart(lock *types.Lock, config *types.HelmChart) *types.LockedChart {
+	for i, lockedChart := range lock.Charts {
+		if lockedChart.ReleaseName == config.ReleaseName &&
+			lockedChart.Name == config.Name &&
+			lockedChart.RepoUrl == config.RepoUrl &&
+			lockedChart.Constraint == config.Version {
+			return &lock.Charts[i]
+		}
+	}
+	return nil
+}
+
+// verifyDigest checks chart package against pinned digest of chart config
+func verifyDigest(config *types.HelmChart, chartFile string) error {
+	if config.Digest == "" {
+		return nil
+	}
+	digest, err := fileDigest(chartFile)
+	if err != nil {
+		return err
+	}
+	if digest != strings.TrimPrefix(config.Digest, digestPrefix) {
+		return fmt.Errorf("chart %q version %q digest %s%s does not match pinned digest %s", config.Name, config.Version, digestPrefix, digest, config.Digest)
+	}
+	return nil
+}
+
+// packagedChartVersion reads version from Chart.yaml of chart package
+func packagedChartVersion(chartFile string) (string, error) {
+	content, err := readChartFile(chartFile, "Chart.yaml")
+	if err != nil {
+		return "", err
+	}
+	if content == nil {
+		return "", fmt.Errorf("chart package %q does not contain Chart.yaml", chartFile)
+	}
+	var chart struct {
+		Version string `yaml:"version"`
+	}
+	if err := yaml.Unmarshal(content, &chart); err != nil {
+		return "", fmt.Errorf("parsing Chart.yaml of chart package %q failed error: %s", chartFile, err)
+	}
+	return chart.Version, nil
+}
@@ -0,0 +1,246 @@
+package helm
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	types "github.com/librucha/krmgen/internal"
+	cons "github.com/librucha/krmgen/internal/utils"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// chartPackage returns gzipped tar of chart with given version
+func chartPackage(t *testing.T, name string, version string) []byte {
+	var buffer bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buffer)
+	tarWriter := tar.NewWriter(gzipWriter)
+	// nested Chart.yaml of sub chart is listed first and must not be taken as chart version
+	files := [][2]string{
+		{name + "/charts/sub/Chart.yaml", "apiVersion: v2\nname: sub\nversion: 0.0.1\n"},
+		{name + "/Chart.yaml", fmt.Sprintf("apiVersion: v2\nname: %s\nversion: %s\n", name, version)},
+		{name + "/templates/configmap.yaml", "kind: ConfigMap\n"},
+	}
+	for _, file := range files {
+		if err := tarWriter.WriteHeader(&tar.Header{Name: file[0], Mode: 0644, Size: int64(len(file[1]))}); err != nil {
+			t.Fatal(err)
+		}
+		_, _ = tarWriter.Write([]byte(file[1]))
+	}
+	_ = tarWriter.Close()
+	_ = gzipWriter.Close()
+	return buffer.Bytes()
+}
+
+// fakeHelmPullPackage creates helm executable copying given chart package to --destination
+func fakeHelmPullPackage(t *testing.T, chart []byte) string {
+	binDir := t.TempDir()
+	chartFile := filepath.Join(binDir, "chart.tgz")
+	_ = os.WriteFile(chartFile, chart, 0644)
+	script := fmt.Sprintf(`#!/bin/sh
+while [ "$#" -gt 0 ]; do
+  case "$1" in
+    --destination) destination="$2"; shift ;;
+  esac
+  shift
+done
+cp %q "$destination/chart.tgz"
+`, chartFile)
+	helmFile := filepath.Join(binDir, "helm")
+	if err := os.WriteFile(helmFile, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(cons.EnvHelmExecutable, helmFile)
+	return chartFile
+}
+
+func sha256Digest(content []byte) string {
+	hash := sha256.Sum256(content)
+	return digestPrefix + hex.EncodeToString(hash[:])
+}
+
+func TestLockCharts(t *testing.T) {
+	chart := chartPackage(t, "app", "5.4.7")
+	fakeHelmPullPackage(t, chart)
+
+	charts := []types.HelmChart{
+		{Name: "app", RepoUrl: "https://charts.example.com", ReleaseName: "app", Version: "~5.4"},
+		{Name: "app", RepoUrl: "https://charts.example.com", ReleaseName: "app", Version: "~5.4"},
+		{Name: "local", RepoUrl: "./charts/local", ReleaseName: "local"},
+	}
+	configs := []*types.Config{{Helm: &types.Helm{Charts: &charts}}}
+
+	got, err := LockCharts(configs, t.TempDir())
+	if err != nil {
+		t.Fatalf("LockCharts() error = %v", err)
+	}
+	want := &types.Lock{
+		ApiVersion: lockApiVersion,
+		Kind:       lockKind,
+		Charts: []types.LockedChart{{
+			ReleaseName: "app",
+			Name:        "app",
+			RepoUrl:     "https://charts.example.com",
+			Constraint:  "~5.4",
+			Version:     "5.4.7",
+			Digest:      sha256Digest(chart),
+		}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("LockCharts() got = %v, want %v", got, want)
+	}
+
+	workDir := t.TempDir()
+	if err := WriteLock(workDir, got); err != nil {
+		t.Fatalf("WriteLock() error = %v", err)
+	}
+	read, err := ReadLock(workDir)
+	if err != nil || !reflect.DeepEqual(read, want) {
+		t.Errorf("ReadLock() got = %v, %v, want %v", read, err, want)
+	}
+	if missing, err := ReadLock(t.TempDir()); missing != nil || err != nil {
+		t.Errorf("ReadLock() of missing lock got = %v, %v", missing, err)
+	}
+}
+
+func TestLockCharts_dependencies(t *testing.T) {
+	fakeHelmDependencyBuild(t)
+	workDir := t.TempDir()
+	chartDir := filepath.Join(workDir, "charts", "app")
+	_ = os.MkdirAll(chartDir, 0755)
+	chartYaml := "apiVersion: v2\nname: app\nversion: 1.0.0\ndependencies:\n  - name: redis\n    version: 17.0.0\n    repository: https://charts.bitnami.com/bitnami\n"
+	_ = os.WriteFile(filepath.Join(chartDir, "Chart.yaml"), []byte(chartYaml), 0644)
+
+	charts := []types.HelmChart{
+		{Name: "app", RepoUrl: "./charts/app", ReleaseName: "app"},
+		{Name: "vendored", RepoUrl: "./charts/vendored", ReleaseName: "vendored"},
+	}
+	configs := []*types.Config{{Helm: &types.Helm{Charts: &charts}}}
+	got, err := LockCharts(configs, workDir)
+	if err != nil {
+		t.Fatalf("LockCharts() error = %v", err)
+	}
+	want := []types.LockedChart{{
+		ReleaseName:  "app",
+		Name:         "app",
+		RepoUrl:      "./charts/app",
+		Dependencies: []types.LockedDependency{{File: "redis-17.0.0.tgz", Digest: sha256Digest([]byte("redis\n"))}},
+	}}
+	if !reflect.DeepEqual(got.Charts, want) {
+		t.Errorf("LockCharts() got = %v, want %v", got.Charts, want)
+	}
+	if _, err := os.Stat(filepath.Join(chartDir, "charts")); err == nil {
+		t.Errorf("LockCharts() modified chart in work dir")
+	}
+
+	config := &types.HelmChart{Name: "app", RepoUrl: "./charts/app", ReleaseName: "app"}
+	if err := applyLock(got, newLocalHelmGenerator(config, workDir)); err != nil {
+		t.Fatalf("applyLock() error = %v", err)
+	}
+	if !reflect.DeepEqual(config.LockedDependencies, want[0].Dependencies) {
+		t.Errorf("applyLock() locked dependencies = %v, want %v", config.LockedDependencies, want[0].Dependencies)
+	}
+}
+
+func Test_applyLock(t *testing.T) {
+	lock := &types.Lock{Charts: []types.LockedChart{{
+		ReleaseName: "app",
+		Name:        "app",
+		RepoUrl:     "oci://registry.example.com/charts/app",
+		Constraint:  "~5.4",
+		Version:     "5.4.7",
+		Digest:      "sha256:abc",
+	}}}
+	tests := []struct {
+		name    string
+		lock    *types.Lock
+		config  *types.HelmChart
+		want    *types.HelmChart
+		wantErr bool
+	}{
+		{
+			name:   "locked chart",
+			lock:   lock,
+			config: &types.HelmChart{Name: "app", RepoUrl: "oci://registry.example.com/charts/app", ReleaseName: "app", Version: "~5.4"},
+			want:   &types.HelmChart{Name: "app", RepoUrl: "oci://registry.example.com/charts/app", ReleaseName: "app", Version: "5.4.7", Digest: "sha256:abc"},
+		},
+		{
+			name:    "changed constraint",
+			lock:    lock,
+			config:  &types.HelmChart{Name: "app", RepoUrl: "oci://registry.example.com/charts/app", ReleaseName: "app", Version: "~5.5"},
+			wantErr: true,
+		},
+		{
+			name:   "local chart is not locked",
+			lock:   lock,
+			config: &types.HelmChart{Name: "local", RepoUrl: "./charts/local", ReleaseName: "local"},
+			want:   &types.HelmChart{Name: "local", RepoUrl: "./charts/local", ReleaseName: "local"},
+		},
+		{
+			name:   "no lock",
+			config: &types.HelmChart{Name: "app", RepoUrl: "oci://registry.example.com/charts/app", ReleaseName: "app", Version: "~5.4"},
+			want:   &types.HelmChart{Name: "app", RepoUrl: "oci://registry.example.com/charts/app", ReleaseName: "app", Version: "~5.4"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generator, _ := newGenerator(tt.config, "/work")
+			err := applyLock(tt.lock, generator)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("applyLock() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && !reflect.DeepEqual(generator.getConfig(), tt.want) {
+				t.Errorf("applyLock() got = %v, want %v", generator.getConfig(), tt.want)
+			}
+		})
+	}
+}
+
+func Test_getChartArgs_digest(t *testing.T) {
+	chart := chartPackage(t, "app", "5.4.7")
+	fakeHelmPullPackage(t, chart)
+
+	tests := []struct {
+		name    string
+		digest  string
+		wantErr bool
+	}{
+		{
+			name:   "matching digest",
+			digest: sha256Digest(chart),
+		},
+		{
+			name:    "digest mismatch",
+			digest:  sha256Digest([]byte("other chart")),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &types.HelmChart{Name: "app", RepoUrl: "https://charts.example.com", ReleaseName: "app", Version: "5.4.7", Digest: tt.digest}
+			args, err := getChartArgs(newRepoHelmGenerator(config), testHelmEnv(t), t.TempDir())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("getChartArgs() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && len(args) != 1 {
+				t.Errorf("getChartArgs() = %v, want pulled chart file", args)
+			}
+		})
+	}
+}
+
+func Test_packagedChartVersion(t *testing.T) {
+	chartFile := filepath.Join(t.TempDir(), "app.tgz")
+	_ = os.WriteFile(chartFile, chartPackage(t, "app", "1.2.3"), 0644)
+	got, err := packagedChartVersion(chartFile)
+	if err != nil || got != "1.2.3" {
+		t.Errorf("packagedChartVersion() got = %v, %v, want 1.2.3", got, err)
+	}
+}
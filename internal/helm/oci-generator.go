@@ -1,9 +1,8 @@
 package helm
 
 import (
+	"fmt"
 	types "github.com/librucha/krmgen/internal"
-	"github.com/librucha/krmgen/internal/tool"
-	"log"
 	"regexp"
 	"strings"
 )
@@ -34,22 +33,54 @@ func (g ociHelmGenerator) chartIdShort() string {
 	return g.chartId()
 }
 
-func (g ociHelmGenerator) login() {
+func (g ociHelmGenerator) login(env *helmEnv) error {
 	args := []string{"registry", "login", g.chartIdShort()}
-	args = g.addCredentials(args)
+	credentialsArgs, password := credentialsArgs(g.config)
+	args = append(args, credentialsArgs...)
+	if g.config.CaFile != "" {
+		args = append(args, "--ca-file", g.config.CaFile)
+	}
+	if g.config.CertFile != "" {
+		args = append(args, "--cert-file", g.config.CertFile)
+	}
+	if keyFile := clientKeyFile(g.config); keyFile != "" {
+		args = append(args, "--key-file", keyFile)
+	}
+	if g.config.InsecureSkipTlsVerify || g.config.PlainHttp {
+		args = append(args, "--insecure")
+	}
 
-	_, _, err := tool.RunCommand(helmExecutable(), args...)
-	if err != nil {
-		log.Fatalf("login to helm registry %q failed reason: %q", g.chartIdShort(), err.Error())
+	if _, err := env.runWithInput(password, args...); err != nil {
+		return fmt.Errorf("login to helm registry %q failed reason: %s", g.chartIdShort(), err)
 	}
+	return nil
 }
 
-func (g ociHelmGenerator) addCredentials(in []string) []string {
-	return append(in, credentialsArgs(g.config)...)
+func (g ociHelmGenerator) addRepoArgs(in []string) []string {
+	in = append(in, g.config.RepoUrl)
+	return append(in, g.tlsArgs()...)
 }
 
-func (g ociHelmGenerator) addRepoArgs(in []string) []string {
-	return append(in, g.config.RepoUrl)
+func (g ociHelmGenerator) addPullArgs(in []string) []string {
+	in = append(in, g.config.RepoUrl)
+	return append(in, g.tlsArgs()...)
+}
+
+func (g ociHelmGenerator) tlsArgs() []string {
+	args := tlsArgs(g.config)
+	if g.config.PlainHttp {
+		args = append(args, "--plain-http")
+	}
+	return args
+}
+
+func (g ociHelmGenerator) verifyPulled(env *helmEnv, digest string) error {
+	return verifyOciSignature(g.config, env, g.config.RepoUrl, digest)
+}
+
+func (g ociHelmGenerator) addVerifyArgs(in []string) []string {
+	// helm does not support provenance of oci charts
+	return in
 }
 
 func newOciHelmGenerator(config *types.HelmChart) ociHelmGenerator {
@@ -0,0 +1,105 @@
+package helm
+
+import (
+	"fmt"
+	types "github.com/librucha/krmgen/internal"
+	"github.com/librucha/krmgen/internal/manifest"
+	"github.com/librucha/krmgen/internal/tool"
+)
+
+// postRender applies post render steps of chart to helm output
+func postRender(output string, steps []types.PostRenderStep) (string, error) {
+	for i, step := range steps {
+		var err error
+		if step.Exec != nil {
+			output, err = execStep(output, step.Exec)
+		} else {
+			output, err = transformStep(output, step)
+		}
+		if err != nil {
+			return "", fmt.Errorf("post render step %d failed error: %s", i+1, err)
+		}
+	}
+	return output, nil
+}
+
+func transformStep(output string, step types.PostRenderStep) (string, error) {
+	resources, err := manifest.Parse(output)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case step.Json6902 != nil:
+		matched := false
+		for _, resource := range resources {
+			if manifest.Matches(resource, step.Json6902.Target) {
+				matched = true
+				if err := manifest.ApplyJson6902(resource, step.Json6902.Operations); err != nil {
+					return "", err
+				}
+			}
+		}
+		if !matched {
+			return "", fmt.Errorf("json6902 target %+v matches no resource", step.Json6902.Target)
+		}
+	case step.StrategicMerge != nil:
+		resources, err = applyStrategicMerge(resources, step.StrategicMerge)
+		if err != nil {
+			return "", err
+		}
+	case step.Remove != nil:
+		var kept []map[string]any
+		for _, resource := range resources {
+			if !manifest.Matches(resource, step.Remove) {
+				kept = append(kept, resource)
+			}
+		}
+		resources = kept
+	case step.Namespace != "":
+		manifest.SetNamespace(resources, step.Namespace, step.ClusterScopedKinds)
+	default:
+		return "", fmt.Errorf("step has no transformation defined")
+	}
+	return manifest.Format(resources)
+}
+
+// applyStrategicMerge merges patch to resource with the same kind, name and namespace if given
+func applyStrategicMerge(resources []map[string]any, patch map[string]any) ([]map[string]any, error) {
+	group, version := manifest.GroupVersion(patch)
+	target := &types.ResourceSelector{
+		Group:     group,
+		Version:   version,
+		Kind:      manifest.Kind(patch),
+		Name:      manifest.Name(patch),
+		Namespace: manifest.Namespace(patch),
+	}
+	if target.Kind == "" || target.Name == "" {
+		return nil, fmt.Errorf("strategic merge patch requires kind and metadata.name")
+	}
+	var result []map[string]any
+	matched := false
+	for _, resource := range resources {
+		if manifest.Matches(resource, target) {
+			matched = true
+			if manifest.IsDeletePatch(patch) {
+				continue
+			}
+			if err := manifest.ApplyStrategicMerge(resource, patch); err != nil {
+				return nil, err
+			}
+		}
+		result = append(result, resource)
+	}
+	if !matched {
+		return nil, fmt.Errorf("strategic merge patch target %s %q matches no resource", target.Kind, target.Name)
+	}
+	return result, nil
+}
+
+func execStep(output string, step *types.ExecStep) (string, error) {
+	stdOut, stdErr, err := tool.RunCommandWithInput(output, step.Command, step.Args...)
+	if err != nil {
+		return "", fmt.Errorf("run command %q finished with error %v. Error output %v", step.Command, err, stdErr)
+	}
+	return stdOut, nil
+}
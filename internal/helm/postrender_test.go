@@ -0,0 +1,146 @@
+package helm
+
+import (
+	types "github.com/librucha/krmgen/internal"
+	"testing"
+)
+
+func Test_postRender(t *testing.T) {
+	output := `apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: app
+  namespace: default
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+  namespace: default
+spec:
+  replicas: 1
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: app
+subjects:
+  - kind: ServiceAccount
+    name: app
+    namespace: default
+`
+	tests := []struct {
+		name    string
+		steps   []types.PostRenderStep
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "json6902, remove and namespace",
+			steps: []types.PostRenderStep{
+				{Json6902: &types.Json6902Patch{
+					Target:     &types.ResourceSelector{Group: "apps", Kind: "Deployment", Name: "app"},
+					Operations: []types.JsonPatchOperation{{Op: "replace", Path: "/spec/replicas", Value: 3}},
+				}},
+				{Remove: &types.ResourceSelector{Kind: "ServiceAccount"}},
+				{Namespace: "team-a"},
+			},
+			want: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+  namespace: team-a
+spec:
+  replicas: 3
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: app
+subjects:
+  - kind: ServiceAccount
+    name: app
+    namespace: team-a
+`,
+		},
+		{
+			name: "strategic merge",
+			steps: []types.PostRenderStep{
+				{StrategicMerge: map[string]any{
+					"apiVersion": "apps/v1",
+					"kind":       "Deployment",
+					"metadata":   map[string]any{"name": "app", "labels": map[string]any{"team": "a"}},
+				}},
+				{StrategicMerge: map[string]any{
+					"kind":     "ServiceAccount",
+					"metadata": map[string]any{"name": "app"},
+					"$patch":   "delete",
+				}},
+				{Remove: &types.ResourceSelector{Kind: "ClusterRoleBinding"}},
+			},
+			want: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  labels:
+    team: a
+  name: app
+  namespace: default
+spec:
+  replicas: 1
+`,
+		},
+		{
+			name: "exec",
+			steps: []types.PostRenderStep{
+				{Exec: &types.ExecStep{Command: "sed", Args: []string{"s/replicas: 1/replicas: 5/"}}},
+				{Remove: &types.ResourceSelector{Kind: "ServiceAccount"}},
+				{Remove: &types.ResourceSelector{Kind: "ClusterRoleBinding"}},
+			},
+			want: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+  namespace: default
+spec:
+  replicas: 5
+`,
+		},
+		// Rainy scenarios
+		{
+			name: "json6902 without match",
+			steps: []types.PostRenderStep{
+				{Json6902: &types.Json6902Patch{Target: &types.ResourceSelector{Kind: "StatefulSet"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "strategic merge without name",
+			steps: []types.PostRenderStep{
+				{StrategicMerge: map[string]any{"kind": "Deployment"}},
+			},
+			wantErr: true,
+		},
+		{
+			name:    "empty step",
+			steps:   []types.PostRenderStep{{}},
+			wantErr: true,
+		},
+		{
+			name:    "failing exec",
+			steps:   []types.PostRenderStep{{Exec: &types.ExecStep{Command: "false"}}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := postRender(output, tt.steps)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("postRender() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("postRender() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
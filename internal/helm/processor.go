@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"github.com/google/uuid"
 	types "github.com/librucha/krmgen/internal"
+	"github.com/librucha/krmgen/internal/template"
+	"github.com/librucha/krmgen/internal/template/argocd"
+	"github.com/librucha/krmgen/internal/template/kube"
 	"github.com/librucha/krmgen/internal/tool"
 	cons "github.com/librucha/krmgen/internal/utils"
 	"gopkg.in/yaml.v3"
@@ -11,6 +14,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
@@ -26,28 +30,49 @@ func helmExecutable() string {
 	return helm
 }
 
-func TemplateHelmCharts(helmConfig *types.Helm, workDir string) (string, error) {
-
-	helmOutput := strings.Builder{}
-	for _, helmChartConfig := range *helmConfig.Charts {
-		generator, err := newGenerator(&helmChartConfig)
+// TemplateHelmCharts templates helm charts of config and returns output of each chart release in declared order
+func TemplateHelmCharts(config *types.Config, workDir string) ([]types.RenderedChart, error) {
+	data := template.NewData(config, workDir)
+	lock, err := ReadLock(workDir)
+	if err != nil {
+		return nil, err
+	}
+	var renderedCharts []types.RenderedChart
+	for _, helmChartConfig := range *config.Helm.Charts {
+		generator, err := prepareGenerator(config.Helm, &helmChartConfig, workDir, lock)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 
-		helmTemplate, err := templateHelm(generator, workDir)
+		helmTemplate, err := templateHelm(generator, workDir, data.ForChart(&helmChartConfig))
 		if err != nil {
-			return "", err
-		}
-		_, err = helmOutput.WriteString(helmTemplate)
-		if err != nil {
-			return "", err
+			return nil, err
 		}
+		renderedCharts = append(renderedCharts, types.RenderedChart{ReleaseName: helmChartConfig.ReleaseName, Manifest: helmTemplate})
+	}
+	return renderedCharts, nil
+}
+
+// prepareGenerator returns generator of chart with resolved repository, locked version and keyring
+func prepareGenerator(helm *types.Helm, helmChartConfig *types.HelmChart, workDir string, lock *types.Lock) (generator, error) {
+	if err := resolveRepository(helm, helmChartConfig, workDir); err != nil {
+		return nil, err
+	}
+	generator, err := newGenerator(helmChartConfig, workDir)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := generator.(chartSource); ok {
+		resolveDependencyRepositories(helm, helmChartConfig, workDir)
 	}
-	return helmOutput.String(), nil
+	if err := applyLock(lock, generator); err != nil {
+		return nil, err
+	}
+	resolveKeyring(helmChartConfig, workDir)
+	return generator, nil
 }
 
-func templateHelm(generator generator, workDir string) (string, error) {
+func templateHelm(generator generator, workDir string, data *template.Data) (string, error) {
 	config := generator.getConfig()
 	tempDir, err := os.MkdirTemp(os.TempDir(), config.ReleaseName)
 	if err != nil {
@@ -60,41 +85,111 @@ func templateHelm(generator generator, workDir string) (string, error) {
 	args := []string{
 		"template",
 		config.ReleaseName,
-		"--include-crds",
-	}
-	if config.Version != "" {
-		args = append(args, "--version", config.Version)
 	}
+	args = append(args, renderArgs(config)...)
+	args = append(args, capabilitiesArgs(config)...)
 
-	args = generator.addRepoArgs(args)
-
-	if credentialsProvided(generator.getConfig()) {
-		generator.login()
-		args = generator.addCredentials(args)
+	env, err := newHelmEnv(tempDir)
+	if err != nil {
+		return "", err
+	}
+	chartArgs, err := getChartArgs(generator, env, tempDir)
+	if err != nil {
+		return "", err
 	}
+	args = append(args, chartArgs...)
 
-	valuesArgs, err := getValuesArgs(config, workDir, tempDir)
+	valuesArgs, err := getValuesArgs(config, workDir, tempDir, data)
 	if err != nil {
 		return "", err
 	}
 	args = append(args, valuesArgs...)
 
-	stdOut, stdErr, err := tool.RunCommand(helmExecutable(), args...)
+	stdOut, err := env.run(args...)
+	if err != nil {
+		return "", err
+	}
+	stdOut, err = processHooks(stdOut, config.Hooks)
 	if err != nil {
-		return "", fmt.Errorf("run command %q finished with error %v. Error output %v", helmExecutable(), err, stdErr)
+		return "", err
+	}
+	if len(config.PostRender) > 0 {
+		return postRender(stdOut, config.PostRender)
 	}
 	return stdOut, nil
 }
 
-func getValuesArgs(helmChartConfig *types.HelmChart, workDir string, tempDir string) ([]string, error) {
+// getChartArgs returns args locating the chart in chart cache, temp dir or remote repo
+func getChartArgs(generator generator, env *helmEnv, tempDir string) ([]string, error) {
+	config := generator.getConfig()
+	if err := materializeTlsFiles(config, tempDir); err != nil {
+		return nil, err
+	}
+	if f, ok := generator.(fetcher); ok {
+		if err := f.fetch(tempDir); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkVerifiable(generator); err != nil {
+		return nil, err
+	}
+	if err := buildDependencies(generator, env, tempDir); err != nil {
+		return nil, err
+	}
+	if cachedArgs := cachedChartArgs(generator, tempDir); cachedArgs != nil {
+		if err := verifyDigest(config, cachedArgs[0]); err != nil {
+			return nil, err
+		}
+		return cachedArgs, nil
+	}
+	// login to repository only when chart is not served from cache
+	if err := authenticate(generator, env); err != nil {
+		return nil, err
+	}
+	if config.Digest != "" || verifiesPulled(generator) || cachesChart(generator) {
+		puller, ok := generator.(cacheable)
+		if !ok {
+			return nil, fmt.Errorf("digest of chart %q can be pinned only for helm repo and oci charts", config.Name)
+		}
+		chartFile, err := pullChart(generator, puller, env, tempDir)
+		if err != nil {
+			return nil, err
+		}
+		if err := verifyDigest(config, chartFile); err != nil {
+			return nil, err
+		}
+		if cachesChart(generator) {
+			if err := activeChartCache.put(config, chartFile); err != nil {
+				return nil, err
+			}
+		}
+		return []string{chartFile}, nil
+	}
+
+	var args []string
+	if config.Version != "" {
+		args = append(args, "--version", config.Version)
+	}
+	args = generator.addRepoArgs(args)
+	return addVerifyArgs(generator, args), nil
+}
+
+func getValuesArgs(helmChartConfig *types.HelmChart, workDir string, tempDir string, data *template.Data) ([]string, error) {
 	var args []string
-	valuesFile := helmChartConfig.ValuesFile
-	if valuesFile != "" {
-		filePath := filepath.Join(workDir, valuesFile)
-		args = append(args, "--values", filePath)
+	valuesFiles, err := resolveValuesFiles(helmChartConfig, workDir)
+	if err != nil {
+		return nil, err
 	}
-	if len(helmChartConfig.ValuesInline) > 0 {
-		valuesInlineYaml, err := yaml.Marshal(helmChartConfig.ValuesInline)
+	for i, valuesFile := range valuesFiles {
+		evaluatedFile, err := evaluateValuesFile(valuesFile, filepath.Join(tempDir, fmt.Sprintf("helm-values-file-%d.yaml", i)), data)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "--values", evaluatedFile)
+	}
+	valuesInline := inlineValues(helmChartConfig)
+	if len(valuesInline) > 0 {
+		valuesInlineYaml, err := yaml.Marshal(valuesInline)
 		if err != nil {
 			return nil, err
 		}
@@ -107,3 +202,117 @@ func getValuesArgs(helmChartConfig *types.HelmChart, workDir string, tempDir str
 	}
 	return args, nil
 }
+
+// inlineValues returns valuesInline with global and subchart values merged over it
+func inlineValues(helmChartConfig *types.HelmChart) map[string]any {
+	values := helmChartConfig.ValuesInline
+	if len(helmChartConfig.ValuesGlobal) > 0 {
+		values = tool.MergeMaps(values, map[string]any{"global": helmChartConfig.ValuesGlobal})
+	}
+	for subchart, subchartValues := range helmChartConfig.ValuesSubcharts {
+		values = tool.MergeMaps(values, map[string]any{subchart: subchartValues})
+	}
+	return values
+}
+
+// resolveValuesFiles returns absolute paths of valuesFile and expanded valuesFiles patterns in merge order
+func resolveValuesFiles(helmChartConfig *types.HelmChart, workDir string) ([]string, error) {
+	var files []string
+	if helmChartConfig.ValuesFile != "" {
+		files = append(files, resolvePath(workDir, helmChartConfig.ValuesFile))
+	}
+	for _, pattern := range helmChartConfig.ValuesFiles {
+		matches, err := filepath.Glob(resolvePath(workDir, pattern))
+		if err != nil {
+			return nil, fmt.Errorf("values files pattern %q is not valid error: %s", pattern, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("values files pattern %q of chart %q matches no file", pattern, helmChartConfig.ReleaseName)
+		}
+		sort.Strings(matches)
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+// resolvePath returns path relative to work dir as absolute. Absolute path is kept.
+func resolvePath(workDir string, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(workDir, path)
+}
+
+// evaluateValuesFile evaluates krmgen templates of values file opted in by krmgen:template directive into target file.
+// Values file which is not opted in is returned as is so chart tpl strings like {{ .Release.Name }} are kept.
+func evaluateValuesFile(valuesFile string, targetFile string, data *template.Data) (string, error) {
+	content, err := os.ReadFile(valuesFile)
+	if err != nil {
+		return "", fmt.Errorf("reading values file %q failed error: %s", valuesFile, err)
+	}
+	optedIn, err := template.HasTemplateDirective(string(content))
+	if err != nil {
+		return "", fmt.Errorf("parsing directives of values file %q failed error: %s", valuesFile, err)
+	}
+	if !optedIn {
+		return valuesFile, nil
+	}
+	evaluated, err := template.EvalFileTemplates(string(content), data.ForFile(valuesFile))
+	if err != nil {
+		return "", fmt.Errorf("template evaluation of values file %q failed error: %s", valuesFile, err)
+	}
+	if err := os.WriteFile(targetFile, []byte(evaluated), 0600); err != nil {
+		return "", err
+	}
+	return targetFile, nil
+}
+
+// renderArgs returns args selecting rendered content of the chart
+func renderArgs(helmChartConfig *types.HelmChart) []string {
+	var args []string
+	if helmChartConfig.IncludeCrds == nil || *helmChartConfig.IncludeCrds {
+		args = append(args, "--include-crds")
+	}
+	if helmChartConfig.SkipTests {
+		args = append(args, "--skip-tests")
+	}
+	if helmChartConfig.SkipSchemaValidation {
+		args = append(args, "--skip-schema-validation")
+	}
+	return args
+}
+
+const EnvAppNamespace = argocd.EnvAppKeyPrefix + "NAMESPACE"
+const EnvKubeVersion = kube.EnvKeyPrefix + "VERSION"
+const EnvKubeApiVersions = kube.EnvKeyPrefix + "API_VERSIONS"
+
+// capabilitiesArgs returns namespace and cluster capabilities args defaulted from ArgoCD env
+func capabilitiesArgs(helmChartConfig *types.HelmChart) []string {
+	var args []string
+	namespace := helmChartConfig.Namespace
+	if namespace == "" {
+		namespace = os.Getenv(EnvAppNamespace)
+	}
+	if namespace != "" {
+		args = append(args, "--namespace", namespace)
+	}
+	kubeVersion := helmChartConfig.KubeVersion
+	if kubeVersion == "" {
+		kubeVersion = os.Getenv(EnvKubeVersion)
+	}
+	if kubeVersion != "" {
+		args = append(args, "--kube-version", kubeVersion)
+	}
+	apiVersions := helmChartConfig.ApiVersions
+	if len(apiVersions) == 0 {
+		for _, apiVersion := range strings.Split(os.Getenv(EnvKubeApiVersions), ",") {
+			if apiVersion = strings.TrimSpace(apiVersion); apiVersion != "" {
+				apiVersions = append(apiVersions, apiVersion)
+			}
+		}
+	}
+	for _, apiVersion := range apiVersions {
+		args = append(args, "--api-versions", apiVersion)
+	}
+	return args
+}
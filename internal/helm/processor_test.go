@@ -1,9 +1,13 @@
 package helm
 
 import (
+	types "github.com/librucha/krmgen/internal"
+	"github.com/librucha/krmgen/internal/template"
 	cons "github.com/librucha/krmgen/internal/utils"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -38,3 +42,193 @@ func Test_helmExecutable(t *testing.T) {
 		})
 	}
 }
+
+func Test_getValuesArgs(t *testing.T) {
+	workDir := t.TempDir()
+	_ = os.MkdirAll(filepath.Join(workDir, "values"), 0755)
+	_ = os.WriteFile(filepath.Join(workDir, "values.yaml"), []byte("# krmgen:template\nrelease: '{{ .Chart.Release }}'"), 0644)
+	_ = os.WriteFile(filepath.Join(workDir, "values", "20-region.yaml"), []byte("# krmgen:template\nregion: '{{ .Vars.region }}'"), 0644)
+	_ = os.WriteFile(filepath.Join(workDir, "values", "10-common.yaml"), []byte(`common: true`), 0644)
+	_ = os.WriteFile(filepath.Join(workDir, "chart-tpl.yaml"), []byte(`fullname: '{{ include "app.fullname" . }}-{{ .Release.Name }}'`), 0644)
+	data := template.NewData(&types.Config{Vars: map[string]any{"region": "westeurope"}}, workDir)
+
+	tests := []struct {
+		name        string
+		chart       *types.HelmChart
+		wantContent []string
+		wantErr     bool
+	}{
+		{
+			name:        "no values",
+			chart:       &types.HelmChart{ReleaseName: "app"},
+			wantContent: nil,
+		},
+		{
+			name:        "evaluated values file",
+			chart:       &types.HelmChart{ReleaseName: "app", ValuesFile: "values.yaml"},
+			wantContent: []string{"# krmgen:template\nrelease: 'app'"},
+		},
+		{
+			name:        "values file without directive keeps chart templates",
+			chart:       &types.HelmChart{ReleaseName: "app", ValuesFile: "chart-tpl.yaml"},
+			wantContent: []string{`fullname: '{{ include "app.fullname" . }}-{{ .Release.Name }}'`},
+		},
+		{
+			name:        "absolute values files pattern",
+			chart:       &types.HelmChart{ReleaseName: "app", ValuesFiles: []string{filepath.Join(workDir, "values", "1*.yaml")}},
+			wantContent: []string{"common: true"},
+		},
+		{
+			name:        "values files glob in lexical order",
+			chart:       &types.HelmChart{ReleaseName: "app", ValuesFile: "values.yaml", ValuesFiles: []string{"values/*.yaml"}},
+			wantContent: []string{"# krmgen:template\nrelease: 'app'", "common: true", "# krmgen:template\nregion: 'westeurope'"},
+		},
+		{
+			name:        "values inline last",
+			chart:       &types.HelmChart{ReleaseName: "app", ValuesFiles: []string{"values/10-common.yaml"}, ValuesInline: map[string]any{"inline": true}},
+			wantContent: []string{"common: true", "inline: true\n"},
+		},
+		// Rainy scenarios
+		{
+			name:    "missing values file",
+			chart:   &types.HelmChart{ReleaseName: "app", ValuesFile: "missing.yaml"},
+			wantErr: true,
+		},
+		{
+			name:    "pattern without match",
+			chart:   &types.HelmChart{ReleaseName: "app", ValuesFiles: []string{"missing/*.yaml"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := getValuesArgs(tt.chart, workDir, t.TempDir(), data.ForChart(tt.chart))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("getValuesArgs() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			var gotContent []string
+			for i := 0; i+1 < len(got); i += 2 {
+				if got[i] != "--values" {
+					t.Errorf("getValuesArgs() unexpected arg %q", got[i])
+				}
+				content, _ := os.ReadFile(got[i+1])
+				gotContent = append(gotContent, string(content))
+			}
+			if !reflect.DeepEqual(gotContent, tt.wantContent) {
+				t.Errorf("getValuesArgs() content = %q, want %q", gotContent, tt.wantContent)
+			}
+		})
+	}
+}
+
+func Test_capabilitiesArgs(t *testing.T) {
+	tests := []struct {
+		name  string
+		chart *types.HelmChart
+		env   map[string]string
+		want  []string
+	}{
+		{
+			name:  "nothing provided",
+			chart: &types.HelmChart{},
+			want:  nil,
+		},
+		{
+			name:  "provided inline",
+			chart: &types.HelmChart{Namespace: "team-a", KubeVersion: "1.27.3", ApiVersions: []string{"monitoring.coreos.com/v1"}},
+			env:   map[string]string{EnvAppNamespace: "argocd-ns", EnvKubeVersion: "1.25"},
+			want:  []string{"--namespace", "team-a", "--kube-version", "1.27.3", "--api-versions", "monitoring.coreos.com/v1"},
+		},
+		{
+			name:  "provided in ENV",
+			chart: &types.HelmChart{},
+			env: map[string]string{
+				EnvAppNamespace:    "argocd-ns",
+				EnvKubeVersion:     "1.25",
+				EnvKubeApiVersions: "apps/v1, networking.k8s.io/v1/Ingress,",
+			},
+			want: []string{"--namespace", "argocd-ns", "--kube-version", "1.25", "--api-versions", "apps/v1", "--api-versions", "networking.k8s.io/v1/Ingress"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				_ = os.Setenv(k, v)
+			}
+			if got := capabilitiesArgs(tt.chart); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("capabilitiesArgs() = %v, want %v", got, tt.want)
+			}
+			for k := range tt.env {
+				_ = os.Unsetenv(k)
+			}
+		})
+	}
+}
+
+func Test_inlineValues(t *testing.T) {
+	tests := []struct {
+		name  string
+		chart *types.HelmChart
+		want  map[string]any
+	}{
+		{
+			name:  "only inline",
+			chart: &types.HelmChart{ValuesInline: map[string]any{"replicas": 2}},
+			want:  map[string]any{"replicas": 2},
+		},
+		{
+			name: "global and subcharts merged over inline",
+			chart: &types.HelmChart{
+				ValuesInline:    map[string]any{"replicas": 2, "global": map[string]any{"env": "dev", "region": "westeurope"}, "redis": map[string]any{"enabled": true}},
+				ValuesGlobal:    map[string]any{"env": "prod"},
+				ValuesSubcharts: map[string]map[string]any{"redis": {"architecture": "standalone"}, "postgresql": {"enabled": false}},
+			},
+			want: map[string]any{
+				"replicas":   2,
+				"global":     map[string]any{"env": "prod", "region": "westeurope"},
+				"redis":      map[string]any{"enabled": true, "architecture": "standalone"},
+				"postgresql": map[string]any{"enabled": false},
+			},
+		},
+		{
+			name:  "nothing",
+			chart: &types.HelmChart{},
+			want:  nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := inlineValues(tt.chart); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("inlineValues() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_renderArgs(t *testing.T) {
+	excludeCrds := false
+	tests := []struct {
+		name  string
+		chart *types.HelmChart
+		want  []string
+	}{
+		{
+			name:  "defaults",
+			chart: &types.HelmChart{},
+			want:  []string{"--include-crds"},
+		},
+		{
+			name:  "all options",
+			chart: &types.HelmChart{IncludeCrds: &excludeCrds, SkipTests: true, SkipSchemaValidation: true},
+			want:  []string{"--skip-tests", "--skip-schema-validation"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderArgs(tt.chart); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("renderArgs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
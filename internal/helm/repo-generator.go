@@ -6,6 +6,9 @@ import (
 	"regexp"
 )
 
+// repoAlias is name of repo added to isolated helm env of private helm repo
+const repoAlias = "krmgen"
+
 var helmUrlRegexp = regexp.MustCompile("\\w+://([0-9a-zA-Z-_]+).*")
 
 type repoHelmGenerator struct {
@@ -28,16 +31,42 @@ func (g repoHelmGenerator) chartIdShort() string {
 	return g.config.RepoUrl
 }
 
-func (g repoHelmGenerator) login() {
-	// login on helm repo is not supported
+// login adds the repo with credentials to isolated helm env so password is not passed as argument
+func (g repoHelmGenerator) login(env *helmEnv) error {
+	args := []string{"repo", "add", repoAlias, g.config.RepoUrl}
+	credentialsArgs, password := credentialsArgs(g.config)
+	args = append(args, credentialsArgs...)
+	args = append(args, tlsArgs(g.config)...)
+	if _, err := env.runWithInput(password, args...); err != nil {
+		return fmt.Errorf("login to helm repo %q failed reason: %s", g.config.RepoUrl, err)
+	}
+	return nil
 }
 
-func (g repoHelmGenerator) addCredentials(in []string) []string {
-	return append(in, credentialsArgs(g.config)...)
+func (g repoHelmGenerator) addRepoArgs(in []string) []string {
+	if credentialsProvided(g.config) {
+		in = append(in, repoAlias+"/"+g.config.Name)
+	} else {
+		in = append(in, "--repo", g.config.RepoUrl, "--release-name", g.config.Name)
+	}
+	return append(in, tlsArgs(g.config)...)
 }
 
-func (g repoHelmGenerator) addRepoArgs(in []string) []string {
-	return append(in, "--repo", g.config.RepoUrl, "--release-name", g.config.Name)
+func (g repoHelmGenerator) addPullArgs(in []string) []string {
+	if credentialsProvided(g.config) {
+		in = append(in, repoAlias+"/"+g.config.Name)
+	} else {
+		in = append(in, g.config.Name, "--repo", g.config.RepoUrl)
+	}
+	return append(in, tlsArgs(g.config)...)
+}
+
+func (g repoHelmGenerator) addVerifyArgs(in []string) []string {
+	in = append(in, "--verify")
+	if g.config.Keyring != "" {
+		in = append(in, "--keyring", g.config.Keyring)
+	}
+	return in
 }
 
 func newRepoHelmGenerator(config *types.HelmChart) repoHelmGenerator {
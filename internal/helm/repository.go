@@ -0,0 +1,89 @@
+package helm
+
+import (
+	"fmt"
+	types "github.com/librucha/krmgen/internal"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const repositoryRefPrefix = "@"
+
+// resolveRepository replaces "@name" repo reference of chart by declared repository.
+// Credentials and TLS settings of the chart take precedence over repository ones.
+func resolveRepository(helm *types.Helm, config *types.HelmChart, workDir string) error {
+	if strings.HasPrefix(config.RepoUrl, repositoryRefPrefix) {
+		name := strings.TrimPrefix(config.RepoUrl, repositoryRefPrefix)
+		repository := findRepository(helm, name)
+		if repository == nil {
+			return fmt.Errorf("helm repository %q referenced by chart %q is not declared in helm.repositories", name, config.Name)
+		}
+		config.RepoUrl = repository.Url
+		if config.Username == "" {
+			config.Username = repository.Username
+		}
+		if config.Username == "" && repository.UsernameEnv != "" {
+			config.Username = os.Getenv(repository.UsernameEnv)
+		}
+		if config.Password == "" {
+			config.Password = repository.Password
+		}
+		if config.Password == "" && repository.PasswordEnv != "" {
+			config.Password = os.Getenv(repository.PasswordEnv)
+		}
+		if config.CaFile == "" {
+			config.CaFile = repository.CaFile
+		}
+		if config.CertFile == "" && config.KeyFile == "" {
+			config.CertFile, config.KeyFile = repository.CertFile, repository.KeyFile
+		}
+		config.InsecureSkipTlsVerify = config.InsecureSkipTlsVerify || repository.InsecureSkipTlsVerify
+		config.PlainHttp = config.PlainHttp || repository.PlainHttp
+	}
+	resolveFiles(workDir, &config.CaFile, &config.CertFile, &config.KeyFile)
+	return nil
+}
+
+// resolveDependencyRepositories gives declared repositories to chart which dependencies are built by helm.
+// Their files are resolved against work dir because dependencies are built out of it.
+func resolveDependencyRepositories(helm *types.Helm, config *types.HelmChart, workDir string) {
+	if helm == nil || config.Repositories != nil {
+		return
+	}
+	for _, repository := range helm.Repositories {
+		resolveFiles(workDir, &repository.CaFile, &repository.CertFile, &repository.KeyFile)
+		config.Repositories = append(config.Repositories, repository)
+	}
+}
+
+// resolveFiles makes file paths relative to work dir absolute. Inline PEM is kept.
+func resolveFiles(workDir string, files ...*string) {
+	for _, file := range files {
+		if *file != "" && !isInlinePem(*file) && !filepath.IsAbs(*file) {
+			*file = filepath.Join(workDir, *file)
+		}
+	}
+}
+
+// findRepositoryByUrl returns declared repository with given url
+func findRepositoryByUrl(repositories []types.HelmRepository, url string) *types.HelmRepository {
+	for i, repository := range repositories {
+		if strings.TrimSuffix(repository.Url, "/") == strings.TrimSuffix(url, "/") {
+			return &repositories[i]
+		}
+	}
+	return nil
+}
+
+func findRepository(helm *types.Helm, name string) *types.HelmRepository {
+	if helm == nil {
+		return nil
+	}
+	for i, repository := range helm.Repositories {
+		if repository.Name == name {
+			return &helm.Repositories[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package helm
+
+import (
+	types "github.com/librucha/krmgen/internal"
+	"reflect"
+	"testing"
+)
+
+func Test_resolveRepository(t *testing.T) {
+	t.Setenv("PRIVATE_USER", "env-user")
+	t.Setenv("PRIVATE_PASSWORD", "env-password")
+	helm := &types.Helm{Repositories: []types.HelmRepository{
+		{
+			Name:     "public",
+			Url:      "https://charts.example.com",
+			Username: "user",
+			Password: "password",
+		},
+		{
+			Name:                  "private",
+			Url:                   "oci://registry.example.com/charts/app",
+			UsernameEnv:           "PRIVATE_USER",
+			PasswordEnv:           "PRIVATE_PASSWORD",
+			CaFile:                "certs/ca.pem",
+			InsecureSkipTlsVerify: true,
+		},
+	}}
+	tests := []struct {
+		name    string
+		config  *types.HelmChart
+		want    *types.HelmChart
+		wantErr bool
+	}{
+		{
+			name:   "repository credentials",
+			config: &types.HelmChart{Name: "app", RepoUrl: "@public"},
+			want:   &types.HelmChart{Name: "app", RepoUrl: "https://charts.example.com", Username: "user", Password: "password"},
+		},
+		{
+			name:   "repository credentials from env and tls",
+			config: &types.HelmChart{Name: "app", RepoUrl: "@private"},
+			want:   &types.HelmChart{Name: "app", RepoUrl: "oci://registry.example.com/charts/app", Username: "env-user", Password: "env-password", CaFile: "/work/certs/ca.pem", InsecureSkipTlsVerify: true},
+		},
+		{
+			name:   "chart credentials take precedence",
+			config: &types.HelmChart{Name: "app", RepoUrl: "@public", Username: "chart-user", Password: "chart-password"},
+			want:   &types.HelmChart{Name: "app", RepoUrl: "https://charts.example.com", Username: "chart-user", Password: "chart-password"},
+		},
+		{
+			name:   "direct repo url",
+			config: &types.HelmChart{Name: "app", RepoUrl: "https://other.example.com", CaFile: "/etc/ca.pem"},
+			want:   &types.HelmChart{Name: "app", RepoUrl: "https://other.example.com", CaFile: "/etc/ca.pem"},
+		},
+		{
+			name:    "unknown repository",
+			config:  &types.HelmChart{Name: "app", RepoUrl: "@unknown"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := resolveRepository(helm, tt.config, "/work")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("resolveRepository() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && !reflect.DeepEqual(tt.config, tt.want) {
+				t.Errorf("resolveRepository() got = %v, want %v", tt.config, tt.want)
+			}
+		})
+	}
+}
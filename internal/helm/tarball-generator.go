@@ -0,0 +1,93 @@
+package helm
+
+import (
+	"fmt"
+	types "github.com/librucha/krmgen/internal"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// tarballHelmGenerator renders chart packaged as .tgz available on direct URL
+type tarballHelmGenerator struct {
+	config    *types.HelmChart
+	chartFile string
+}
+
+func (g *tarballHelmGenerator) getConfig() *types.HelmChart {
+	return g.config
+}
+
+func (g *tarballHelmGenerator) chartId() string {
+	return g.config.RepoUrl
+}
+
+func (g *tarballHelmGenerator) chartIdShort() string {
+	tarballUrl, err := url.Parse(g.config.RepoUrl)
+	if err != nil {
+		return g.config.RepoUrl
+	}
+	return tarballUrl.Host
+}
+
+func (g *tarballHelmGenerator) login(*helmEnv) error {
+	// credentials are used directly by download
+	return nil
+}
+
+func (g *tarballHelmGenerator) addRepoArgs(in []string) []string {
+	return append(in, g.chartFile)
+}
+
+// fetch downloads the chart package into temp dir
+func (g *tarballHelmGenerator) fetch(tempDir string) error {
+	req, err := http.NewRequest(http.MethodGet, g.config.RepoUrl, nil)
+	if err != nil {
+		return err
+	}
+	username, password := credentials(g.config)
+	if username != "" || password != "" {
+		req.SetBasicAuth(username, password)
+	}
+	client, err := httpClient(g.config)
+	if err != nil {
+		return err
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("downloading chart %q failed error: %s", g.config.RepoUrl, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("downloading chart %q failed status: %s", g.config.RepoUrl, res.Status)
+	}
+	chartFile := filepath.Join(tempDir, "chart-"+g.config.ReleaseName+".tgz")
+	file, err := os.Create(chartFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := io.Copy(file, res.Body); err != nil {
+		return fmt.Errorf("downloading chart %q failed error: %s", g.config.RepoUrl, err)
+	}
+	g.chartFile = chartFile
+	return nil
+}
+
+// isTarballUrl returns true if url path points to packaged chart
+func isTarballUrl(rawUrl string) bool {
+	tarballUrl, err := url.Parse(rawUrl)
+	if err != nil {
+		return false
+	}
+	ext := strings.ToLower(path.Ext(tarballUrl.Path))
+	return ext == ".tgz" || strings.HasSuffix(strings.ToLower(tarballUrl.Path), ".tar.gz")
+}
+
+func newTarballHelmGenerator(config *types.HelmChart) *tarballHelmGenerator {
+	return &tarballHelmGenerator{config: config}
+}
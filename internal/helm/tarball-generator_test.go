@@ -0,0 +1,67 @@
+package helm
+
+import (
+	types "github.com/librucha/krmgen/internal"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func Test_tarballHelmGenerator_fetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if r.URL.Path == "/private/app-1.0.0.tgz" && (!ok || username != "user" || password != "secret") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/app-1.0.0.tgz" && r.URL.Path != "/private/app-1.0.0.tgz" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte("chart content"))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		config  *types.HelmChart
+		wantErr bool
+	}{
+		{
+			name:   "public chart",
+			config: &types.HelmChart{RepoUrl: server.URL + "/app-1.0.0.tgz", ReleaseName: "app"},
+		},
+		{
+			name:   "private chart",
+			config: &types.HelmChart{RepoUrl: server.URL + "/private/app-1.0.0.tgz", ReleaseName: "app", Username: "user", Password: "secret"},
+		},
+		{
+			name:    "private chart without credentials",
+			config:  &types.HelmChart{RepoUrl: server.URL + "/private/app-1.0.0.tgz", ReleaseName: "app"},
+			wantErr: true,
+		},
+		{
+			name:    "missing chart",
+			config:  &types.HelmChart{RepoUrl: server.URL + "/missing-1.0.0.tgz", ReleaseName: "app"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newTarballHelmGenerator(tt.config)
+			err := g.fetch(t.TempDir())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("fetch() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil {
+				return
+			}
+			content, _ := os.ReadFile(g.addRepoArgs(nil)[0])
+			if string(content) != "chart content" {
+				t.Errorf("fetch() content = %q", content)
+			}
+		})
+	}
+}
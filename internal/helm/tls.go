@@ -0,0 +1,102 @@
+package helm
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	types "github.com/librucha/krmgen/internal"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const pemPrefix = "-----BEGIN"
+
+// isInlinePem returns true if value is PEM content instead of file path, e.g. result of azCert or azSec
+func isInlinePem(value string) bool {
+	return strings.HasPrefix(strings.TrimSpace(value), pemPrefix)
+}
+
+// materializeTlsFiles writes inline PEM of CA, client certificate and key to temp dir and uses the files instead
+func materializeTlsFiles(config *types.HelmChart, tempDir string) error {
+	files := []struct {
+		value *string
+		name  string
+	}{
+		{&config.CaFile, "tls-ca.pem"},
+		{&config.CertFile, "tls-cert.pem"},
+		{&config.KeyFile, "tls-key.pem"},
+	}
+	for _, file := range files {
+		if !isInlinePem(*file.value) {
+			continue
+		}
+		path := filepath.Join(tempDir, file.name)
+		if err := os.WriteFile(path, []byte(*file.value), 0600); err != nil {
+			return err
+		}
+		*file.value = path
+	}
+	return nil
+}
+
+// tlsArgs returns TLS args of helm commands talking to repo server
+func tlsArgs(config *types.HelmChart) []string {
+	var args []string
+	if config.CaFile != "" {
+		args = append(args, "--ca-file", config.CaFile)
+	}
+	if config.CertFile != "" {
+		args = append(args, "--cert-file", config.CertFile)
+	}
+	if keyFile := clientKeyFile(config); keyFile != "" {
+		args = append(args, "--key-file", keyFile)
+	}
+	if config.InsecureSkipTlsVerify {
+		args = append(args, "--insecure-skip-tls-verify")
+	}
+	return args
+}
+
+// clientKeyFile returns key of client certificate. Key can be bundled with the certificate like in PEM secret
+// of Azure certificate and helm needs it passed as key file too.
+func clientKeyFile(config *types.HelmChart) string {
+	if config.KeyFile == "" {
+		return config.CertFile
+	}
+	return config.KeyFile
+}
+
+// httpTimeout limits chart downloads so unresponsive server does not block generation forever
+const httpTimeout = 5 * time.Minute
+
+// httpClient returns client trusting chart CA and presenting client certificate of chart config
+func httpClient(config *types.HelmChart) (*http.Client, error) {
+	if config.CaFile == "" && config.CertFile == "" && !config.InsecureSkipTlsVerify {
+		return &http.Client{Timeout: httpTimeout}, nil
+	}
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.InsecureSkipTlsVerify}
+	if config.CaFile != "" {
+		caPem, err := os.ReadFile(config.CaFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA file of chart %q failed error: %s", config.Name, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPem) {
+			return nil, fmt.Errorf("CA file %q of chart %q contains no certificate", config.CaFile, config.Name)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if config.CertFile != "" {
+		certificate, err := tls.LoadX509KeyPair(config.CertFile, clientKeyFile(config))
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate of chart %q failed error: %s", config.Name, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport, Timeout: httpTimeout}, nil
+}
@@ -0,0 +1,499 @@
+package helm
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	types "github.com/librucha/krmgen/internal"
+	"github.com/librucha/krmgen/internal/template"
+	"github.com/librucha/krmgen/internal/tool"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const valuesSchemaFileName = "values.schema.json"
+
+// ValuesReport contains merged values of chart and problems found in them
+type ValuesReport struct {
+	// Values are chart defaults merged with valuesFile and valuesInline
+	Values map[string]any
+	// SchemaErrors are violations of chart values.schema.json
+	SchemaErrors []string
+	// UnknownKeys are dotted paths of valuesInline keys not present in chart defaults
+	UnknownKeys []string
+}
+
+// HasProblems returns true if values violate chart schema or contain unknown keys
+func (r *ValuesReport) HasProblems() bool {
+	return len(r.SchemaErrors) > 0 || len(r.UnknownKeys) > 0
+}
+
+// ChartValues merges values of chart selected by release name or chart name in the order helm applies them
+// and checks them against chart schema and defaults
+func ChartValues(config *types.Config, workDir string, chartName string) (*ValuesReport, error) {
+	if !config.HasHelm() {
+		return nil, fmt.Errorf("config declares no helm charts")
+	}
+	helmChartConfig := findChart(*config.Helm.Charts, chartName)
+	if helmChartConfig == nil {
+		return nil, fmt.Errorf("chart %q is not declared in config", chartName)
+	}
+	lock, err := ReadLock(workDir)
+	if err != nil {
+		return nil, err
+	}
+	generator, err := prepareGenerator(config.Helm, helmChartConfig, workDir, lock)
+	if err != nil {
+		return nil, err
+	}
+
+	tempDir, err := os.MkdirTemp(os.TempDir(), helmChartConfig.ReleaseName)
+	if err != nil {
+		return nil, err
+	}
+	defer func(path string) {
+		_ = os.RemoveAll(path)
+	}(tempDir)
+
+	env, err := newHelmEnv(tempDir)
+	if err != nil {
+		return nil, err
+	}
+	chartPath, err := locateChart(generator, env, tempDir)
+	if err != nil {
+		return nil, err
+	}
+	chart, err := loadValuesChart(chartPath)
+	if err != nil {
+		return nil, err
+	}
+	defaults := chart.defaults()
+
+	data := template.NewData(config, workDir).ForChart(helmChartConfig)
+	valuesArgs, err := getValuesArgs(helmChartConfig, workDir, tempDir, data)
+	if err != nil {
+		return nil, err
+	}
+	userValues := map[string]any{}
+	for i := 1; i < len(valuesArgs); i += 2 {
+		fileValues, err := readValuesFile(valuesArgs[i])
+		if err != nil {
+			return nil, err
+		}
+		userValues = tool.MergeMaps(userValues, fileValues)
+	}
+
+	report := &ValuesReport{Values: coalesceValues(defaults, userValues)}
+	report.SchemaErrors, err = chart.validate(report.Values, "")
+	if err != nil {
+		return nil, err
+	}
+	report.UnknownKeys = unknownKeys(helmChartConfig.ValuesInline, defaults, chart.missingSubcharts())
+	return report, nil
+}
+
+// findChart returns chart config matching release name or chart name
+func findChart(charts []types.HelmChart, chartName string) *types.HelmChart {
+	for i := range charts {
+		if charts[i].ReleaseName == chartName {
+			return &charts[i]
+		}
+	}
+	for i := range charts {
+		if charts[i].Name == chartName {
+			return &charts[i]
+		}
+	}
+	return nil
+}
+
+// locateChart returns local chart dir or package. Charts from helm repos and registries are pulled.
+func locateChart(generator generator, env *helmEnv, tempDir string) (string, error) {
+	chartArgs, err := getChartArgs(generator, env, tempDir)
+	if err != nil {
+		return "", err
+	}
+	puller, ok := generator.(cacheable)
+	if !ok {
+		// local, git and tarball charts are referenced by path
+		return generator.addRepoArgs(nil)[0], nil
+	}
+	if len(chartArgs) == 1 {
+		// chart already pulled into temp dir
+		return chartArgs[0], nil
+	}
+	return pullChart(generator, puller, env, tempDir)
+}
+
+func readValuesFile(valuesFile string) (map[string]any, error) {
+	content, err := os.ReadFile(valuesFile)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]any{}
+	if err := yaml.Unmarshal(content, &values); err != nil {
+		return nil, fmt.Errorf("parsing values file %q failed error: %s", valuesFile, err)
+	}
+	return values, nil
+}
+
+// valuesChart is chart with its values, schema and subcharts vendored in charts dir
+type valuesChart struct {
+	path         string
+	name         string
+	values       map[string]any
+	schema       []byte
+	dependencies []chartDependency
+	subcharts    map[string]*valuesChart
+}
+
+// loadValuesChart loads values of chart dir or package and of its subcharts
+func loadValuesChart(chartPath string) (*valuesChart, error) {
+	files, err := readChartFiles(chartPath)
+	if err != nil {
+		return nil, err
+	}
+	return newValuesChart(chartPath, files)
+}
+
+// newValuesChart creates chart of files keyed by slash separated path relative to chart dir
+func newValuesChart(chartPath string, files map[string][]byte) (*valuesChart, error) {
+	var metadata struct {
+		Name         string            `yaml:"name"`
+		Dependencies []chartDependency `yaml:"dependencies"`
+	}
+	if err := yaml.Unmarshal(files["Chart.yaml"], &metadata); err != nil {
+		return nil, fmt.Errorf("parsing Chart.yaml of chart %q failed error: %s", chartPath, err)
+	}
+	chart := &valuesChart{
+		path:         chartPath,
+		name:         metadata.Name,
+		values:       map[string]any{},
+		schema:       files[valuesSchemaFileName],
+		dependencies: metadata.Dependencies,
+		subcharts:    map[string]*valuesChart{},
+	}
+	if err := yaml.Unmarshal(files["values.yaml"], &chart.values); err != nil {
+		return nil, fmt.Errorf("parsing values.yaml of chart %q failed error: %s", chartPath, err)
+	}
+
+	subchartFiles := map[string]map[string][]byte{}
+	for name, content := range files {
+		subPath, found := strings.CutPrefix(name, "charts/")
+		if !found {
+			continue
+		}
+		if dir, file, found := strings.Cut(subPath, "/"); found {
+			if subchartFiles[dir] == nil {
+				subchartFiles[dir] = map[string][]byte{}
+			}
+			subchartFiles[dir][file] = content
+		} else if strings.HasSuffix(subPath, ".tgz") {
+			packageFiles, err := readPackageFiles(bytes.NewReader(content))
+			if err != nil {
+				return nil, fmt.Errorf("reading subchart %q of chart %q failed error: %s", subPath, chartPath, err)
+			}
+			subchartFiles[subPath] = packageFiles
+		}
+	}
+	for subPath, files := range subchartFiles {
+		if files["Chart.yaml"] == nil {
+			continue
+		}
+		subchart, err := newValuesChart(path.Join(chartPath, "charts", subPath), files)
+		if err != nil {
+			return nil, err
+		}
+		chart.subcharts[subchart.name] = subchart
+	}
+	return chart, nil
+}
+
+// valuesKey returns key of dependency values in parent chart values
+func (d chartDependency) valuesKey() string {
+	if d.Alias != "" {
+		return d.Alias
+	}
+	return d.Name
+}
+
+// defaults returns chart values with defaults of subcharts merged under their alias like helm does
+func (c *valuesChart) defaults() map[string]any {
+	values := c.values
+	for _, dependency := range c.dependencies {
+		subchart := c.subcharts[dependency.Name]
+		if subchart == nil {
+			continue
+		}
+		key := dependency.valuesKey()
+		parentValues, _ := values[key].(map[string]any)
+		values = tool.MergeMaps(values, map[string]any{key: coalesceValues(subchart.defaults(), parentValues)})
+	}
+	return values
+}
+
+// missingSubcharts returns values keys of dependencies not vendored in charts dir
+func (c *valuesChart) missingSubcharts() map[string]bool {
+	missing := map[string]bool{}
+	for _, dependency := range c.dependencies {
+		if c.subcharts[dependency.Name] == nil {
+			missing[dependency.valuesKey()] = true
+		}
+	}
+	return missing
+}
+
+// validate validates values against values.schema.json of chart and values of each subchart against its schema.
+// Subchart values get global values of parent like helm passes them.
+func (c *valuesChart) validate(values map[string]any, location string) ([]string, error) {
+	messages, err := validateValues(c.path, c.schema, values, location)
+	if err != nil {
+		return nil, err
+	}
+	for _, dependency := range c.dependencies {
+		subchart := c.subcharts[dependency.Name]
+		if subchart == nil {
+			continue
+		}
+		key := dependency.valuesKey()
+		subchartValues, _ := values[key].(map[string]any)
+		if global, found := values["global"]; found {
+			subchartValues = tool.MergeMaps(map[string]any{"global": global}, subchartValues)
+		}
+		subchartMessages, err := subchart.validate(subchartValues, location+"/"+key)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, subchartMessages...)
+	}
+	return messages, nil
+}
+
+// coalesceValues merges values over defaults. Keys set to null are deleted like helm does.
+func coalesceValues(defaults map[string]any, values map[string]any) map[string]any {
+	result := make(map[string]any, len(defaults)+len(values))
+	for key, value := range defaults {
+		result[key] = value
+	}
+	for key, value := range values {
+		if value == nil {
+			delete(result, key)
+			continue
+		}
+		valueMap, valueIsMap := value.(map[string]any)
+		defaultMap, defaultIsMap := result[key].(map[string]any)
+		if valueIsMap && defaultIsMap {
+			result[key] = coalesceValues(defaultMap, valueMap)
+		} else {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+// validateValues validates values against values.schema.json of chart. Chart without schema accepts any values.
+func validateValues(chartPath string, schemaContent []byte, values map[string]any, location string) ([]string, error) {
+	if schemaContent == nil {
+		return nil, nil
+	}
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource(valuesSchemaFileName, bytes.NewReader(schemaContent)); err != nil {
+		return nil, fmt.Errorf("parsing %s of chart %q failed error: %s", valuesSchemaFileName, chartPath, err)
+	}
+	schema, err := compiler.Compile(valuesSchemaFileName)
+	if err != nil {
+		return nil, fmt.Errorf("compiling %s of chart %q failed error: %s", valuesSchemaFileName, chartPath, err)
+	}
+	document, err := toJsonDocument(values)
+	if err != nil {
+		return nil, err
+	}
+	err = schema.Validate(document)
+	var validationErr *jsonschema.ValidationError
+	if errors.As(err, &validationErr) {
+		return validationMessages(validationErr, location), nil
+	}
+	return nil, err
+}
+
+// toJsonDocument converts YAML values to types expected by JSON schema validator
+func toJsonDocument(values map[string]any) (any, error) {
+	content, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	var document any
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+// validationMessages flattens validation error to messages of its leaf causes located under given values location
+func validationMessages(err *jsonschema.ValidationError, location string) []string {
+	if len(err.Causes) == 0 {
+		instanceLocation := location + err.InstanceLocation
+		if instanceLocation == "" {
+			instanceLocation = "/"
+		}
+		return []string{instanceLocation + ": " + err.Message}
+	}
+	var messages []string
+	for _, cause := range err.Causes {
+		messages = append(messages, validationMessages(cause, location)...)
+	}
+	return messages
+}
+
+// unknownKeys returns sorted dotted paths of keys in values not present in defaults.
+// Keys under empty or missing default maps, global values and values of missing subcharts are not checked.
+func unknownKeys(values map[string]any, defaults map[string]any, subcharts map[string]bool) []string {
+	var keys []string
+	for key, value := range values {
+		if key == "global" || subcharts[key] {
+			continue
+		}
+		keys = append(keys, unknownNestedKeys(key, key, value, defaults)...)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func unknownNestedKeys(keyPath string, key string, value any, defaults map[string]any) []string {
+	defaultValue, found := defaults[key]
+	if !found {
+		return []string{keyPath}
+	}
+	valueMap, ok := value.(map[string]any)
+	defaultMap, isMap := defaultValue.(map[string]any)
+	if !ok || !isMap || len(defaultMap) == 0 {
+		return nil
+	}
+	var keys []string
+	for nestedKey, nestedValue := range valueMap {
+		keys = append(keys, unknownNestedKeys(keyPath+"."+nestedKey, nestedKey, nestedValue, defaultMap)...)
+	}
+	return keys
+}
+
+// readChartFile reads file from chart dir or chart package. Returns nil content if the file does not exist.
+func readChartFile(chartPath string, fileName string) ([]byte, error) {
+	info, err := os.Stat(chartPath)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		content, err := os.ReadFile(filepath.Join(chartPath, fileName))
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return content, err
+	}
+
+	file, err := os.Open(chartPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading chart package %q failed error: %s", chartPath, err)
+	}
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading chart package %q failed error: %s", chartPath, err)
+		}
+		// chart package contains single top level dir with chart
+		if path.Base(header.Name) != fileName || strings.Count(strings.Trim(header.Name, "/"), "/") != 1 {
+			continue
+		}
+		return io.ReadAll(tarReader)
+	}
+}
+
+// readChartFiles reads Chart.yaml, values, schema and subchart packages of chart dir or package and its subcharts.
+// Files are keyed by slash separated path relative to chart dir.
+func readChartFiles(chartPath string) (map[string][]byte, error) {
+	info, err := os.Stat(chartPath)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		file, err := os.Open(chartPath)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		files, err := readPackageFiles(file)
+		if err != nil {
+			return nil, fmt.Errorf("reading chart package %q failed error: %s", chartPath, err)
+		}
+		return files, nil
+	}
+	files := map[string][]byte{}
+	err = filepath.WalkDir(chartPath, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() || !isValuesChartFile(filePath) {
+			return err
+		}
+		relPath, err := filepath.Rel(chartPath, filePath)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(relPath)], err = os.ReadFile(filePath)
+		return err
+	})
+	return files, err
+}
+
+// readPackageFiles reads files of chart package like readChartFiles
+func readPackageFiles(reader io.Reader) (map[string][]byte, error) {
+	gzipReader, err := gzip.NewReader(reader)
+	if err != nil {
+		return nil, err
+	}
+	files := map[string][]byte{}
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		// chart package contains single top level dir with chart
+		_, name, found := strings.Cut(strings.TrimPrefix(header.Name, "/"), "/")
+		if !found || !header.FileInfo().Mode().IsRegular() || !isValuesChartFile(name) {
+			continue
+		}
+		if files[name], err = io.ReadAll(tarReader); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// isValuesChartFile returns true for chart files needed to merge and validate values
+func isValuesChartFile(name string) bool {
+	switch path.Base(filepath.ToSlash(name)) {
+	case "Chart.yaml", "values.yaml", valuesSchemaFileName:
+		return true
+	}
+	return strings.HasSuffix(name, ".tgz")
+}
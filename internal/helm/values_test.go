@@ -0,0 +1,211 @@
+package helm
+
+import (
+	types "github.com/librucha/krmgen/internal"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestChartValues(t *testing.T) {
+	// missing postgresql dependency is built by helm
+	fakeHelmRecorder(t)
+	workDir := t.TempDir()
+	chartDir := filepath.Join(workDir, "charts", "app")
+	_ = os.MkdirAll(filepath.Join(chartDir, "charts", "redis"), 0755)
+	_ = os.WriteFile(filepath.Join(chartDir, "Chart.yaml"), []byte(`apiVersion: v2
+name: app
+version: 1.0.0
+dependencies:
+  - name: redis
+    alias: cache
+    version: 1.0.0
+  - name: lib
+    version: 1.0.0
+  - name: postgresql
+    version: 1.0.0
+`), 0644)
+	_ = os.WriteFile(filepath.Join(chartDir, "values.yaml"), []byte(`replicaCount: 1
+image:
+  repository: nginx
+  tag: latest
+podAnnotations: {}
+cache:
+  auth:
+    password: secret
+`), 0644)
+	_ = os.WriteFile(filepath.Join(chartDir, valuesSchemaFileName), []byte(`{
+  "type": "object",
+  "properties": {
+    "replicaCount": {"type": "integer", "minimum": 1}
+  }
+}`), 0644)
+	_ = os.WriteFile(filepath.Join(chartDir, "charts", "redis", "Chart.yaml"), []byte("apiVersion: v2\nname: redis\nversion: 1.0.0\n"), 0644)
+	_ = os.WriteFile(filepath.Join(chartDir, "charts", "redis", "values.yaml"), []byte(`port: 6379
+auth:
+  password: ""
+`), 0644)
+	_ = os.WriteFile(filepath.Join(chartDir, "charts", "redis", valuesSchemaFileName), []byte(`{
+  "type": "object",
+  "properties": {
+    "port": {"type": "integer", "maximum": 65535}
+  }
+}`), 0644)
+	_ = os.WriteFile(filepath.Join(chartDir, "charts", "lib-1.0.0.tgz"), chartPackage(t, "lib", "1.0.0"), 0644)
+	_ = os.WriteFile(filepath.Join(workDir, "values.yaml"), []byte(`# krmgen:template
+image:
+  tag: '{{ .Chart.Release }}'
+`), 0644)
+
+	cache := map[string]any{"port": 6379, "auth": map[string]any{"password": "secret"}}
+	tests := []struct {
+		name             string
+		chartName        string
+		valuesInline     map[string]any
+		want             map[string]any
+		wantSchemaErrors []string
+		wantUnknownKeys  []string
+		wantErr          bool
+	}{
+		{
+			name:         "merged in helm order",
+			chartName:    "web",
+			valuesInline: map[string]any{"replicaCount": 3, "podAnnotations": map[string]any{"team": "a"}},
+			want: map[string]any{
+				"replicaCount":   3,
+				"image":          map[string]any{"repository": "nginx", "tag": "web"},
+				"podAnnotations": map[string]any{"team": "a"},
+				"cache":          cache,
+				"lib":            map[string]any{},
+			},
+		},
+		{
+			name:      "selected by chart name",
+			chartName: "app",
+			want: map[string]any{
+				"replicaCount":   1,
+				"image":          map[string]any{"repository": "nginx", "tag": "web"},
+				"podAnnotations": map[string]any{},
+				"cache":          cache,
+				"lib":            map[string]any{},
+			},
+		},
+		{
+			name:         "null deletes key",
+			chartName:    "web",
+			valuesInline: map[string]any{"podAnnotations": nil, "image": map[string]any{"tag": nil}, "cache": map[string]any{"auth": nil}},
+			want: map[string]any{
+				"replicaCount": 1,
+				"image":        map[string]any{"repository": "nginx"},
+				"cache":        map[string]any{"port": 6379},
+				"lib":          map[string]any{},
+			},
+		},
+		{
+			name:             "schema violation",
+			chartName:        "web",
+			valuesInline:     map[string]any{"replicaCount": 0},
+			want:             map[string]any{"replicaCount": 0, "image": map[string]any{"repository": "nginx", "tag": "web"}, "podAnnotations": map[string]any{}, "cache": cache, "lib": map[string]any{}},
+			wantSchemaErrors: []string{"/replicaCount: must be >= 1 but found 0"},
+		},
+		{
+			name:         "aliased subchart values",
+			chartName:    "web",
+			valuesInline: map[string]any{"cache": map[string]any{"port": 70000, "prot": "TCP"}},
+			want: map[string]any{
+				"replicaCount":   1,
+				"image":          map[string]any{"repository": "nginx", "tag": "web"},
+				"podAnnotations": map[string]any{},
+				"cache":          map[string]any{"port": 70000, "prot": "TCP", "auth": map[string]any{"password": "secret"}},
+				"lib":            map[string]any{},
+			},
+			wantSchemaErrors: []string{"/cache/port: must be <= 65535 but found 70000"},
+			wantUnknownKeys:  []string{"cache.prot"},
+		},
+		{
+			name:      "unknown keys",
+			chartName: "web",
+			valuesInline: map[string]any{
+				"replicas":   2,
+				"image":      map[string]any{"pullPolicy": "Always"},
+				"global":     map[string]any{"env": "dev"},
+				"postgresql": map[string]any{"enabled": true},
+			},
+			want: map[string]any{
+				"replicaCount":   1,
+				"replicas":       2,
+				"image":          map[string]any{"repository": "nginx", "tag": "web", "pullPolicy": "Always"},
+				"podAnnotations": map[string]any{},
+				"global":         map[string]any{"env": "dev"},
+				"postgresql":     map[string]any{"enabled": true},
+				"cache":          cache,
+				"lib":            map[string]any{},
+			},
+			wantUnknownKeys: []string{"image.pullPolicy", "replicas"},
+		},
+		{
+			name:      "chart not declared",
+			chartName: "missing",
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &types.Config{Helm: &types.Helm{Charts: &[]types.HelmChart{{
+				Name:         "app",
+				ReleaseName:  "web",
+				RepoUrl:      "./charts/app",
+				ValuesFile:   "values.yaml",
+				ValuesInline: tt.valuesInline,
+			}}}}
+			got, err := ChartValues(config, workDir, tt.chartName)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ChartValues() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got.Values, tt.want) {
+				t.Errorf("ChartValues() values = %v, want %v", got.Values, tt.want)
+			}
+			if !reflect.DeepEqual(got.SchemaErrors, tt.wantSchemaErrors) {
+				t.Errorf("ChartValues() schema errors = %v, want %v", got.SchemaErrors, tt.wantSchemaErrors)
+			}
+			if !reflect.DeepEqual(got.UnknownKeys, tt.wantUnknownKeys) {
+				t.Errorf("ChartValues() unknown keys = %v, want %v", got.UnknownKeys, tt.wantUnknownKeys)
+			}
+		})
+	}
+}
+
+func Test_readChartFile(t *testing.T) {
+	chartFile := filepath.Join(t.TempDir(), "app-1.2.3.tgz")
+	_ = os.WriteFile(chartFile, chartPackage(t, "app", "1.2.3"), 0644)
+	tests := []struct {
+		name     string
+		fileName string
+		want     string
+	}{
+		{
+			name:     "top level file",
+			fileName: "Chart.yaml",
+			want:     "apiVersion: v2\nname: app\nversion: 1.2.3\n",
+		},
+		{
+			name:     "missing file",
+			fileName: "values.yaml",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readChartFile(chartFile, tt.fileName)
+			if err != nil {
+				t.Fatalf("readChartFile() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("readChartFile() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package helm
+
+import (
+	"fmt"
+	types "github.com/librucha/krmgen/internal"
+	"github.com/librucha/krmgen/internal/tool"
+	cons "github.com/librucha/krmgen/internal/utils"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const verifierCosign = "cosign"
+const verifierNotation = "notation"
+
+const envDockerConfig = "DOCKER_CONFIG"
+const envNotationUsername = "NOTATION_USERNAME"
+const envNotationPassword = "NOTATION_PASSWORD"
+
+// verifier is implemented by generators which charts can be verified before templating
+type verifier interface {
+	// addVerifyArgs adds args of helm commands verifying the chart
+	addVerifyArgs([]string) []string
+}
+
+// pulledVerifier is implemented by generators which charts are verified out of helm after pull
+type pulledVerifier interface {
+	// verifyPulled checks signature of artifact with digest printed by helm pull using registry login of helm env
+	verifyPulled(env *helmEnv, digest string) error
+}
+
+// checkVerifiable fails when verification is requested for chart which cannot be verified
+func checkVerifiable(generator generator) error {
+	config := generator.getConfig()
+	if !config.Verify {
+		return nil
+	}
+	if _, ok := generator.(verifier); !ok {
+		return fmt.Errorf("verification of chart %q is supported only for helm repo and oci charts", config.Name)
+	}
+	return nil
+}
+
+// verifiesPulled returns true if chart must be pulled to be verified by digest of pulled artifact
+func verifiesPulled(generator generator) bool {
+	_, ok := generator.(pulledVerifier)
+	return ok && generator.getConfig().Verify
+}
+
+// verifyPull verifies chart pulled by helm pull which printed pullOutput when requested by config.
+// Signature is checked for digest of pulled artifact so a tag moved after verification is never templated.
+func verifyPull(generator generator, env *helmEnv, pullOutput string) error {
+	if !verifiesPulled(generator) {
+		return nil
+	}
+	config := generator.getConfig()
+	digest := pulledDigest(pullOutput)
+	if digest == "" {
+		return fmt.Errorf("verification of chart %q version %q failed: helm pull printed no digest", config.Name, config.Version)
+	}
+	if err := generator.(pulledVerifier).verifyPulled(env, digest); err != nil {
+		return fmt.Errorf("verification of chart %q version %q failed: %s", config.Name, config.Version, err)
+	}
+	return nil
+}
+
+// pulledDigest returns digest of oci artifact from helm pull output line like Digest: sha256:<hex>
+func pulledDigest(pullOutput string) string {
+	for _, line := range strings.Split(pullOutput, "\n") {
+		if digest, found := strings.CutPrefix(strings.TrimSpace(line), "Digest:"); found {
+			return strings.TrimSpace(digest)
+		}
+	}
+	return ""
+}
+
+// addVerifyArgs adds helm verification args when requested by config
+func addVerifyArgs(generator generator, args []string) []string {
+	v, ok := generator.(verifier)
+	if !ok || !generator.getConfig().Verify {
+		return args
+	}
+	return v.addVerifyArgs(args)
+}
+
+// resolveKeyring makes keyring path relative to work dir absolute
+func resolveKeyring(config *types.HelmChart, workDir string) {
+	if config.Keyring != "" && !filepath.IsAbs(config.Keyring) {
+		config.Keyring = filepath.Join(workDir, config.Keyring)
+	}
+}
+
+// verifyOciSignature verifies signature of oci artifact with given digest by cosign or notation.
+// Verifier queries the registry with credentials of registry login in helm env.
+func verifyOciSignature(config *types.HelmChart, env *helmEnv, ref string, digest string) error {
+	ref = fmt.Sprintf("%s@%s", strings.TrimSuffix(strings.TrimPrefix(ref, "oci://"), "/"), digest)
+	var executable string
+	var args []string
+	var err error
+	switch config.VerifyWith {
+	case "", verifierCosign:
+		if config.Keyring == "" {
+			return fmt.Errorf("keyring with cosign public key is required")
+		}
+		executable, err = lookupExecutable(cons.EnvCosignExecutable, verifierCosign)
+		args = []string{"verify", "--key", config.Keyring, ref}
+	case verifierNotation:
+		executable, err = lookupExecutable(cons.EnvNotationExecutable, verifierNotation)
+		args = []string{"verify", ref}
+	default:
+		return fmt.Errorf("unknown verifier %q expected %s or %s", config.VerifyWith, verifierCosign, verifierNotation)
+	}
+	if err != nil {
+		return err
+	}
+	_, stdErr, err := tool.RunCommandWithEnv(verifierEnviron(config, env), "", executable, args...)
+	if err != nil {
+		return fmt.Errorf("run command %q finished with error %v. Error output %v", executable, err, stdErr)
+	}
+	return nil
+}
+
+// verifierEnviron returns env of verifier process with docker config of helm env registry login
+// and registry credentials of chart for notation
+func verifierEnviron(config *types.HelmChart, env *helmEnv) []string {
+	environ := []string{envDockerConfig + "=" + filepath.Dir(env.registryConfig())}
+	if config.VerifyWith == verifierNotation {
+		username, password := credentials(config)
+		if username != "" || password != "" {
+			environ = append(environ, envNotationUsername+"="+username, envNotationPassword+"="+password)
+		}
+	}
+	return environ
+}
+
+// lookupExecutable returns executable from env or OS path
+func lookupExecutable(envKey string, name string) (string, error) {
+	if executable, found := os.LookupEnv(envKey); found {
+		return executable, nil
+	}
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", fmt.Errorf("%s executable not found in OS", name)
+	}
+	return path, nil
+}
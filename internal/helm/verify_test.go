@@ -0,0 +1,225 @@
+package helm
+
+import (
+	"fmt"
+	types "github.com/librucha/krmgen/internal"
+	cons "github.com/librucha/krmgen/internal/utils"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeVerifier creates verifier executable accepting only given ref and recording its args and registry env
+func fakeVerifier(t *testing.T, envKey string, signedRef string) string {
+	binDir := t.TempDir()
+	argsFile := filepath.Join(binDir, "args")
+	script := fmt.Sprintf(`#!/bin/sh
+echo "$@" > %q
+echo "$DOCKER_CONFIG $NOTATION_USERNAME $NOTATION_PASSWORD" > %q.env
+for arg in "$@"; do last="$arg"; done
+[ "$last" = %q ] || { echo "no signature found for $last" >&2; exit 1; }
+`, argsFile, argsFile, signedRef)
+	executable := filepath.Join(binDir, "verifier")
+	if err := os.WriteFile(executable, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(envKey, executable)
+	return argsFile
+}
+
+func Test_verifyPull(t *testing.T) {
+	signedRef := "registry.example.com/charts/app@sha256:signed"
+	cosignArgs := fakeVerifier(t, cons.EnvCosignExecutable, signedRef)
+	notationArgs := fakeVerifier(t, cons.EnvNotationExecutable, signedRef)
+	signedOutput := "Pulled: registry.example.com/charts/app:1.0.0\nDigest: sha256:signed\n"
+	t.Setenv(cons.EnvHelmUsername, "")
+	t.Setenv(cons.EnvHelmPassword, "")
+	env := testHelmEnv(t)
+	dockerConfig := filepath.Dir(env.registryConfig())
+
+	tests := []struct {
+		name       string
+		config     *types.HelmChart
+		pullOutput string
+		wantArgs   string
+		wantEnv    string
+		argsFile   string
+		wantErr    bool
+	}{
+		{
+			name:   "verification disabled",
+			config: &types.HelmChart{Name: "app", RepoUrl: "oci://registry.example.com/charts/app", Version: "1.0.0"},
+		},
+		{
+			name:   "helm repo is verified by helm",
+			config: &types.HelmChart{Name: "app", RepoUrl: "https://charts.example.com", Version: "^1.0.0", Verify: true},
+		},
+		{
+			name:       "cosign signed",
+			config:     &types.HelmChart{Name: "app", RepoUrl: "oci://registry.example.com/charts/app", Version: "1.0.0", Verify: true, Keyring: "/keys/cosign.pub"},
+			pullOutput: signedOutput,
+			argsFile:   cosignArgs,
+			wantArgs:   "verify --key /keys/cosign.pub " + signedRef,
+			wantEnv:    dockerConfig,
+		},
+		{
+			name:       "cosign version range verified by pulled digest",
+			config:     &types.HelmChart{Name: "app", RepoUrl: "oci://registry.example.com/charts/app", Version: "~1.0", Verify: true, Keyring: "/keys/cosign.pub"},
+			pullOutput: signedOutput,
+			argsFile:   cosignArgs,
+			wantArgs:   "verify --key /keys/cosign.pub " + signedRef,
+		},
+		{
+			name:       "cosign unsigned digest",
+			config:     &types.HelmChart{Name: "app", RepoUrl: "oci://registry.example.com/charts/app", Version: "1.0.0", Verify: true, Keyring: "/keys/cosign.pub"},
+			pullOutput: "Pulled: registry.example.com/charts/app:1.0.0\nDigest: sha256:moved\n",
+			wantErr:    true,
+		},
+		{
+			name:       "cosign without key",
+			config:     &types.HelmChart{Name: "app", RepoUrl: "oci://registry.example.com/charts/app", Version: "1.0.0", Verify: true},
+			pullOutput: signedOutput,
+			wantErr:    true,
+		},
+		{
+			name:       "notation signed",
+			config:     &types.HelmChart{Name: "app", RepoUrl: "oci://registry.example.com/charts/app", Version: "1.0.0", Verify: true, VerifyWith: "notation"},
+			pullOutput: signedOutput,
+			argsFile:   notationArgs,
+			wantArgs:   "verify " + signedRef,
+			wantEnv:    dockerConfig,
+		},
+		{
+			name:       "notation signed in private registry",
+			config:     &types.HelmChart{Name: "app", RepoUrl: "oci://registry.example.com/charts/app", Version: "1.0.0", Verify: true, VerifyWith: "notation", Username: "user", Password: "secret"},
+			pullOutput: signedOutput,
+			argsFile:   notationArgs,
+			wantArgs:   "verify " + signedRef,
+			wantEnv:    dockerConfig + " user secret",
+		},
+		{
+			name:       "unknown verifier",
+			config:     &types.HelmChart{Name: "app", RepoUrl: "oci://registry.example.com/charts/app", Version: "1.0.0", Verify: true, VerifyWith: "gpg"},
+			pullOutput: signedOutput,
+			wantErr:    true,
+		},
+		{
+			name:       "digest not printed",
+			config:     &types.HelmChart{Name: "app", RepoUrl: "oci://registry.example.com/charts/app", Version: "1.0.0", Verify: true, Keyring: "/keys/cosign.pub"},
+			pullOutput: "Pulled: registry.example.com/charts/app:1.0.0\n",
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generator, _ := newGenerator(tt.config, "/work")
+			err := verifyPull(generator, env, tt.pullOutput)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("verifyPull() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.argsFile != "" {
+				args, _ := os.ReadFile(tt.argsFile)
+				if got := strings.TrimSpace(string(args)); got != tt.wantArgs {
+					t.Errorf("verifyPull() args = %q, want %q", got, tt.wantArgs)
+				}
+				environ, _ := os.ReadFile(tt.argsFile + ".env")
+				if got := strings.TrimSpace(string(environ)); tt.wantEnv != "" && got != tt.wantEnv {
+					t.Errorf("verifyPull() env = %q, want %q", got, tt.wantEnv)
+				}
+			}
+		})
+	}
+}
+
+func Test_checkVerifiable(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  *types.HelmChart
+		wantErr bool
+	}{
+		{
+			name:   "verification disabled",
+			config: &types.HelmChart{Name: "app", RepoUrl: "./charts/app"},
+		},
+		{
+			name:   "oci chart",
+			config: &types.HelmChart{Name: "app", RepoUrl: "oci://registry.example.com/charts/app", Verify: true},
+		},
+		{
+			name:    "local chart",
+			config:  &types.HelmChart{Name: "app", RepoUrl: "./charts/app", Verify: true},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generator, _ := newGenerator(tt.config, "/work")
+			if err := checkVerifiable(generator); (err != nil) != tt.wantErr {
+				t.Errorf("checkVerifiable() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_getChartArgs_verifiedOciChartNotCached(t *testing.T) {
+	pullsFile := fakeHelmPull(t)
+	fakeVerifier(t, cons.EnvCosignExecutable, "registry.example.com/charts/app@sha256:digest-1.0.0")
+	if err := EnableChartCache(t.TempDir(), 0); err != nil {
+		t.Fatal(err)
+	}
+	defer DisableChartCache()
+
+	config := &types.HelmChart{Name: "app", RepoUrl: "oci://registry.example.com/charts/app", ReleaseName: "app", Version: "1.0.0", Verify: true, Keyring: "/keys/cosign.pub"}
+	for i := 1; i <= 2; i++ {
+		args, err := getChartArgs(newOciHelmGenerator(config), testHelmEnv(t), t.TempDir())
+		if err != nil {
+			t.Fatalf("getChartArgs() error = %v", err)
+		}
+		if content, _ := os.ReadFile(args[0]); string(content) != "chart 1.0.0\n" {
+			t.Errorf("getChartArgs() templated chart content = %q", content)
+		}
+		if countPulls(pullsFile) != i {
+			t.Errorf("getChartArgs() pulls = %d, want %d", countPulls(pullsFile), i)
+		}
+	}
+}
+
+func Test_addVerifyArgs(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *types.HelmChart
+		want   []string
+	}{
+		{
+			name:   "helm repo with keyring",
+			config: &types.HelmChart{RepoUrl: "https://charts.example.com", Verify: true, Keyring: "/keys/pubring.gpg"},
+			want:   []string{"template", "--verify", "--keyring", "/keys/pubring.gpg"},
+		},
+		{
+			name:   "helm repo with default keyring",
+			config: &types.HelmChart{RepoUrl: "https://charts.example.com", Verify: true},
+			want:   []string{"template", "--verify"},
+		},
+		{
+			name:   "oci is verified out of helm",
+			config: &types.HelmChart{RepoUrl: "oci://registry.example.com/charts/app", Verify: true},
+			want:   []string{"template"},
+		},
+		{
+			name:   "verification disabled",
+			config: &types.HelmChart{RepoUrl: "https://charts.example.com"},
+			want:   []string{"template"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generator, _ := newGenerator(tt.config, "/work")
+			if got := addVerifyArgs(generator, []string{"template"}); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("addVerifyArgs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
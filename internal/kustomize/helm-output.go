@@ -0,0 +1,308 @@
+package kustomize
+
+import (
+	types "github.com/librucha/krmgen/internal"
+	"github.com/librucha/krmgen/internal/manifest"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	kyaml "sigs.k8s.io/kustomize/kyaml/yaml"
+	"strings"
+)
+
+const helmRefPrefix = "helm://"
+
+// helmOutputDirName is dir next to root kustomization file with rendered helm charts
+const helmOutputDirName = "krmgen-helm"
+
+const (
+	HelmOutputSingle   = "single"
+	HelmOutputPerChart = "perChart"
+)
+
+const (
+	PositionAppend  = "append"
+	PositionPrepend = "prepend"
+	PositionNone    = "none"
+)
+
+const chartManifestFileName = "resources.yaml"
+
+// HelmReleaseAnnotation marks resources of helm release selected by patch target helm://<releaseName>
+const HelmReleaseAnnotation = "krmgen.io/helm-release"
+
+// helmOutput writes rendered helm charts into kustomization tree and resolves helm:// references to them
+type helmOutput struct {
+	// dir contains chart outputs
+	dir        string
+	mode       string
+	position   string
+	charts     []types.RenderedChart
+	referenced map[string]bool
+	// targeted releases are selected by patch targets and their resources annotated
+	targeted map[string]bool
+	// outputs are chart output files to write keyed by path with releases they contain
+	outputs map[string][]string
+	// kustomizations are kustomization files of chart output dirs to write keyed by path with their kind
+	kustomizations map[string]string
+}
+
+func newHelmOutput(config *types.Kustomize, kustomizeFile string, charts []types.RenderedChart) *helmOutput {
+	h := &helmOutput{
+		dir:            filepath.Join(filepath.Dir(kustomizeFile), helmOutputDirName),
+		mode:           HelmOutputSingle,
+		position:       PositionAppend,
+		charts:         charts,
+		referenced:     map[string]bool{},
+		targeted:       map[string]bool{},
+		outputs:        map[string][]string{},
+		kustomizations: map[string]string{},
+	}
+	if config != nil && config.HelmOutput != nil {
+		if config.HelmOutput.Mode != "" {
+			h.mode = config.HelmOutput.Mode
+		}
+		if config.HelmOutput.Position != "" {
+			h.position = config.HelmOutput.Position
+		}
+	}
+	if h.mode != HelmOutputSingle && h.mode != HelmOutputPerChart {
+		log.Fatalf("helm output mode %q is not supported. Use %q or %q", h.mode, HelmOutputSingle, HelmOutputPerChart)
+	}
+	if h.position != PositionAppend && h.position != PositionPrepend && h.position != PositionNone {
+		log.Fatalf("helm output position %q is not supported. Use %q, %q or %q", h.position, PositionAppend, PositionPrepend, PositionNone)
+	}
+	return h
+}
+
+// resolveRefs replaces helm://<releaseName> in resources and components of kustomization
+// by relative paths of chart output dirs and in patch targets by selector of release annotation.
+// References in patch paths are fatal because chart output is not a patch. Returns true if any reference was replaced.
+func (h *helmOutput) resolveRefs(kustomizeFile string, kustomizeFileYaml map[string]any) bool {
+	baseDir := filepath.Dir(kustomizeFile)
+	resolved := false
+	resolveList := func(key string, resolve func(baseDir string, release string) string) {
+		list := listField(kustomizeFile, kustomizeFileYaml, key)
+		resolvedList := make([]any, len(list))
+		changed := false
+		for i, item := range list {
+			resolvedList[i] = item
+			if release, ok := strings.CutPrefix(item, helmRefPrefix); ok {
+				resolvedList[i] = resolve(baseDir, h.checkRelease(release, kustomizeFile))
+				changed = true
+			}
+		}
+		if changed {
+			kustomizeFileYaml[key] = resolvedList
+			resolved = true
+		}
+	}
+	resolveList("resources", h.chartRef)
+	resolveList("components", h.componentRef)
+
+	patchPaths := listField(kustomizeFile, kustomizeFileYaml, "patchesStrategicMerge")
+	for _, patch := range mapItems(kustomizeFile, kustomizeFileYaml, "patches") {
+		path, _ := patch["path"].(string)
+		patchPaths = append(patchPaths, path)
+		if h.resolveTarget(kustomizeFile, patch) {
+			resolved = true
+		}
+	}
+	for _, path := range patchPaths {
+		if strings.HasPrefix(path, helmRefPrefix) {
+			log.Fatalf("helm output %q referenced as patch by %q is not supported. Select chart resources by patch target %s<releaseName>", path, kustomizeFile, helmRefPrefix)
+		}
+	}
+	return resolved
+}
+
+// resolveTarget replaces patch target helm://<releaseName> or its annotationSelector helm://<releaseName>
+// by selector of resources annotated with the release. Returns true if target was replaced.
+func (h *helmOutput) resolveTarget(kustomizeFile string, patch map[string]any) bool {
+	var release string
+	var found bool
+	switch target := patch["target"].(type) {
+	case string:
+		if release, found = strings.CutPrefix(target, helmRefPrefix); found {
+			patch["target"] = map[string]any{"annotationSelector": h.targetRelease(release, kustomizeFile)}
+		}
+	case map[string]any:
+		selector, _ := target["annotationSelector"].(string)
+		if release, found = strings.CutPrefix(selector, helmRefPrefix); found {
+			target["annotationSelector"] = h.targetRelease(release, kustomizeFile)
+		}
+	}
+	return found
+}
+
+// targetRelease marks release as targeted by patch and returns selector of its resources. Unknown release is fatal.
+func (h *helmOutput) targetRelease(release string, kustomizeFile string) string {
+	if h.find(release) == nil {
+		log.Fatalf("helm release %q targeted by patch of %q is not rendered by config", release, kustomizeFile)
+	}
+	h.targeted[release] = true
+	return HelmReleaseAnnotation + "=" + release
+}
+
+// addResources adds charts not referenced explicitly to resources of root kustomization at configured position
+func (h *helmOutput) addResources(kustomizeFile string, resources []string) []string {
+	if h.position == PositionNone {
+		return resources
+	}
+	baseDir := filepath.Dir(kustomizeFile)
+	var charts []string
+	if h.mode == HelmOutputPerChart {
+		for _, chart := range h.charts {
+			if !h.referenced[chart.ReleaseName] {
+				charts = append(charts, h.chartRef(baseDir, chart.ReleaseName))
+			}
+		}
+	} else {
+		var releases []string
+		for _, chart := range h.charts {
+			if !h.referenced[chart.ReleaseName] {
+				releases = append(releases, chart.ReleaseName)
+			}
+		}
+		if len(releases) > 0 {
+			singleFile := filepath.Join(h.dir, "charts.yaml")
+			h.outputs[singleFile] = releases
+			charts = append(charts, relativePath(baseDir, singleFile))
+		}
+	}
+	if h.position == PositionPrepend {
+		return append(charts, resources...)
+	}
+	return append(resources, charts...)
+}
+
+// checkRelease marks release as referenced. Unknown release is fatal.
+func (h *helmOutput) checkRelease(release string, kustomizeFile string) string {
+	if h.find(release) == nil {
+		log.Fatalf("helm release %q referenced by %q is not rendered by config", release, kustomizeFile)
+	}
+	h.referenced[release] = true
+	return release
+}
+
+func (h *helmOutput) find(release string) *types.RenderedChart {
+	for i, chart := range h.charts {
+		if chart.ReleaseName == release {
+			return &h.charts[i]
+		}
+	}
+	return nil
+}
+
+// chartRef adds kustomization dir with chart output and returns its path relative to base dir
+func (h *helmOutput) chartRef(baseDir string, release string) string {
+	chartDir := filepath.Join(h.dir, release)
+	h.outputs[filepath.Join(chartDir, chartManifestFileName)] = []string{release}
+	h.kustomizations[chartDir] = "Kustomization"
+	return relativePath(baseDir, chartDir)
+}
+
+// componentRef adds component dir with chart output and returns its path relative to base dir
+func (h *helmOutput) componentRef(baseDir string, release string) string {
+	componentDir := filepath.Join(h.dir, release, "component")
+	h.outputs[filepath.Join(componentDir, chartManifestFileName)] = []string{release}
+	h.kustomizations[componentDir] = "Component"
+	return relativePath(baseDir, componentDir)
+}
+
+// write writes chart outputs added by references and resources once all patch targets are known.
+// Resources of targeted releases are annotated with their release.
+func (h *helmOutput) write() {
+	for file, releases := range h.outputs {
+		var manifest strings.Builder
+		for _, release := range releases {
+			manifest.WriteString(h.releaseManifest(release))
+		}
+		writeOutputFile(file, manifest.String())
+	}
+	for dir, kind := range h.kustomizations {
+		apiVersion := "kustomize.config.k8s.io/v1beta1"
+		if kind == "Component" {
+			apiVersion = "kustomize.config.k8s.io/v1alpha1"
+		}
+		writeOutputKustomization(dir, apiVersion, kind)
+	}
+}
+
+// releaseManifest returns rendered manifest of release annotated with the release when targeted by patch
+func (h *helmOutput) releaseManifest(release string) string {
+	chartManifest := h.find(release).Manifest
+	if !h.targeted[release] {
+		return chartManifest
+	}
+	resources, err := manifest.Parse(chartManifest)
+	if err != nil {
+		log.Fatalf("parsing output of helm release %q failed error: %s", release, err)
+	}
+	for _, resource := range resources {
+		manifest.SetAnnotation(resource, HelmReleaseAnnotation, release)
+	}
+	annotated, err := manifest.Format(resources)
+	if err != nil {
+		log.Fatalf("formatting output of helm release %q failed error: %s", release, err)
+	}
+	return annotated
+}
+
+// removeReleaseAnnotations removes release annotation of targeted releases from kustomize output
+func (h *helmOutput) removeReleaseAnnotations(output string) string {
+	if len(h.targeted) == 0 {
+		return output
+	}
+	nodes, err := kio.FromBytes([]byte(output))
+	if err != nil {
+		log.Fatalf("parsing kustomize output failed error: %s", err)
+	}
+	for _, node := range nodes {
+		if _, found := node.GetAnnotations()[HelmReleaseAnnotation]; !found {
+			continue
+		}
+		if err := node.PipeE(kyaml.ClearAnnotation(HelmReleaseAnnotation)); err != nil {
+			log.Fatalf("removing annotation %q failed error: %s", HelmReleaseAnnotation, err)
+		}
+		if err := kyaml.ClearEmptyAnnotations(node); err != nil {
+			log.Fatalf("removing annotation %q failed error: %s", HelmReleaseAnnotation, err)
+		}
+	}
+	result, err := kio.StringAll(nodes)
+	if err != nil {
+		log.Fatalf("formatting kustomize output failed error: %s", err)
+	}
+	return result
+}
+
+func writeOutputKustomization(dir string, apiVersion string, kind string) {
+	content, err := yaml.Marshal(map[string]any{
+		"apiVersion": apiVersion,
+		"kind":       kind,
+		"resources":  []string{chartManifestFileName},
+	})
+	if err != nil {
+		log.Fatalf("marshaling kustomization of helm output failed error: %s", err)
+	}
+	writeOutputFile(filepath.Join(dir, "kustomization.yaml"), string(content))
+}
+
+func writeOutputFile(file string, content string) {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		log.Fatalf("creating helm output dir %q failed error: %s", filepath.Dir(file), err)
+	}
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		log.Fatalf("writing helm output file %q failed error: %s", file, err)
+	}
+}
+
+func relativePath(baseDir string, path string) string {
+	relPath, err := filepath.Rel(baseDir, path)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(relPath)
+}
@@ -0,0 +1,100 @@
+package kustomize
+
+import (
+	types "github.com/librucha/krmgen/internal"
+	"github.com/librucha/krmgen/internal/template/argocd"
+	cons "github.com/librucha/krmgen/internal/utils"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+const envOptionPrefix = argocd.EnvEnvKeyPrefix + "KUSTOMIZE_"
+
+const EnvLoadRestrictor = envOptionPrefix + "LOAD_RESTRICTOR"
+const EnvEnableHelm = envOptionPrefix + "ENABLE_HELM"
+const EnvEnableAlphaPlugins = envOptionPrefix + "ENABLE_ALPHA_PLUGINS"
+const EnvEnableExec = envOptionPrefix + "ENABLE_EXEC"
+const EnvReorder = envOptionPrefix + "REORDER"
+const EnvNetworkEnabled = envOptionPrefix + "NETWORK_ENABLED"
+
+var loadRestrictors = map[string]any{"LoadRestrictionsRootOnly": nil, "LoadRestrictionsNone": nil}
+var reorders = map[string]any{"legacy": nil, "none": nil}
+
+func kubectlExecutable() string {
+	kubectl, found := os.LookupEnv(cons.EnvKubectlExecutable)
+	if !found {
+		path, err := exec.LookPath("kubectl")
+		if err != nil {
+			log.Fatalf("kubectl executable not found in OS")
+		}
+		return path
+	}
+	return kubectl
+}
+
+// resolveOptions returns kustomize options of config overridden by ArgoCD env
+func resolveOptions(config *types.Kustomize) types.KustomizeOptions {
+	var options types.KustomizeOptions
+	if config != nil && config.Options != nil {
+		options = *config.Options
+	}
+	if value, found := os.LookupEnv(EnvLoadRestrictor); found {
+		options.LoadRestrictor = value
+	}
+	if value, found := os.LookupEnv(EnvReorder); found {
+		options.Reorder = value
+	}
+	envBool(EnvEnableHelm, &options.EnableHelm)
+	envBool(EnvEnableAlphaPlugins, &options.EnableAlphaPlugins)
+	envBool(EnvEnableExec, &options.EnableExec)
+	envBool(EnvNetworkEnabled, &options.NetworkEnabled)
+	return options
+}
+
+func envBool(key string, target *bool) {
+	value, found := os.LookupEnv(key)
+	if !found {
+		return
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Fatalf("env %s value %q is not boolean", key, value)
+	}
+	*target = parsed
+}
+
+// optionsArgs returns kubectl kustomize flags of options
+func optionsArgs(options types.KustomizeOptions) []string {
+	var args []string
+	if options.LoadRestrictor != "" {
+		if _, ok := loadRestrictors[options.LoadRestrictor]; !ok {
+			log.Fatalf("kustomize load restrictor %q is not supported. Use LoadRestrictionsRootOnly or LoadRestrictionsNone", options.LoadRestrictor)
+		}
+		args = append(args, "--load-restrictor", options.LoadRestrictor)
+	}
+	if options.EnableHelm {
+		args = append(args, "--enable-helm")
+		// kustomize inflates charts by the same helm as krmgen
+		if helm, found := os.LookupEnv(cons.EnvHelmExecutable); found {
+			args = append(args, "--helm-command", helm)
+		}
+	}
+	if options.EnableAlphaPlugins {
+		args = append(args, "--enable-alpha-plugins")
+	}
+	if options.EnableExec {
+		args = append(args, "--enable-exec")
+	}
+	if options.Reorder != "" {
+		if _, ok := reorders[options.Reorder]; !ok {
+			log.Fatalf("kustomize reorder %q is not supported. Use legacy or none", options.Reorder)
+		}
+		args = append(args, "--reorder", options.Reorder)
+	}
+	if options.NetworkEnabled {
+		args = append(args, "--network")
+	}
+	return args
+}
@@ -0,0 +1,60 @@
+package kustomize
+
+import (
+	types "github.com/librucha/krmgen/internal"
+	cons "github.com/librucha/krmgen/internal/utils"
+	"reflect"
+	"testing"
+)
+
+func Test_optionsArgs(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *types.Kustomize
+		env    map[string]string
+		want   []string
+	}{
+		{
+			name: "no options",
+		},
+		{
+			name: "all options from config",
+			config: &types.Kustomize{Options: &types.KustomizeOptions{
+				LoadRestrictor:     "LoadRestrictionsNone",
+				EnableHelm:         true,
+				EnableAlphaPlugins: true,
+				EnableExec:         true,
+				Reorder:            "none",
+				NetworkEnabled:     true,
+			}},
+			want: []string{"--load-restrictor", "LoadRestrictionsNone", "--enable-helm", "--enable-alpha-plugins", "--enable-exec", "--reorder", "none", "--network"},
+		},
+		{
+			name:   "overridden by ArgoCD env",
+			config: &types.Kustomize{Options: &types.KustomizeOptions{LoadRestrictor: "LoadRestrictionsNone", EnableExec: true}},
+			env: map[string]string{
+				EnvLoadRestrictor: "LoadRestrictionsRootOnly",
+				EnvEnableExec:     "false",
+				EnvEnableHelm:     "true",
+				EnvReorder:        "legacy",
+			},
+			want: []string{"--load-restrictor", "LoadRestrictionsRootOnly", "--enable-helm", "--reorder", "legacy"},
+		},
+		{
+			name:   "helm command of krmgen",
+			config: &types.Kustomize{Options: &types.KustomizeOptions{EnableHelm: true}},
+			env:    map[string]string{cons.EnvHelmExecutable: "/opt/helm"},
+			want:   []string{"--enable-helm", "--helm-command", "/opt/helm"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			if got := optionsArgs(resolveOptions(tt.config)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("optionsArgs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,7 +2,7 @@ package kustomize
 
 import (
 	"fmt"
-	"github.com/google/uuid"
+	types "github.com/librucha/krmgen/internal"
 	"github.com/librucha/krmgen/internal/template"
 	"github.com/librucha/krmgen/internal/tool"
 	log "github.com/sirupsen/logrus"
@@ -18,14 +18,22 @@ import (
 var allowedFileNames = map[string]any{"kustomization.yaml": nil, "kustomization.yml": nil, "kustomization": nil}
 
 // FindKustomizeFile try to find files usable for 'kubectl kustomize' command.
+// Kustomization file of work dir is preferred over files of nested dirs.
 // Returns founded kustomization file path.
 func FindKustomizeFile(workDir string) string {
+	if kustomizeFile := findKustomizeFileInDir(workDir); kustomizeFile != "" {
+		return kustomizeFile
+	}
 	var kustomizeFile string
 	err := filepath.Walk(workDir, func(path string, info fs.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 		if info.IsDir() {
+			// kustomizations of rendered helm charts are not user kustomizations
+			if info.Name() == helmOutputDirName {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 		_, ok := allowedFileNames[strings.ToLower(filepath.Base(path))]
@@ -43,96 +51,206 @@ func FindKustomizeFile(workDir string) string {
 	return kustomizeFile
 }
 
-func BuildKustomize(kustomizeFile string, workDir string, resources string) string {
-	if kustomizeFile == "" {
-		log.Fatalf("no given kustomizeFile parameter")
+// findKustomizeFileInDir returns kustomization file directly in dir or empty string
+func findKustomizeFileInDir(dir string) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
 	}
-	var resourcesFile string
-	if resources != "" {
-		resourcesFile = filepath.Join(workDir, uuid.NewString()+".yml")
-		err := os.WriteFile(resourcesFile, []byte(resources), os.ModePerm)
-		if err != nil {
-			log.Fatalf("write file %qwith resources failed error: %s", resourcesFile, err)
+	for _, entry := range entries {
+		if _, ok := allowedFileNames[strings.ToLower(entry.Name())]; ok && !entry.IsDir() {
+			return filepath.Join(dir, entry.Name())
 		}
 	}
-	prepareKustomizeFile(kustomizeFile, resourcesFile, workDir)
+	return ""
+}
 
+func BuildKustomize(config *types.Config, kustomizeFile string, workDir string, renderedCharts []types.RenderedChart) string {
+	if kustomizeFile == "" {
+		log.Fatalf("no given kustomizeFile parameter")
+	}
 	args := []string{
 		"kustomize",
 		workDir,
 	}
-	stdOut, stdErr, err := tool.RunCommand("kubectl", args...)
+	args = append(args, optionsArgs(resolveOptions(config.Kustomize))...)
+	helm := newHelmOutput(config.Kustomize, kustomizeFile, renderedCharts)
+	prepareKustomizeFile(kustomizeFile, helm, template.NewData(config, workDir))
+
+	stdOut, stdErr, err := tool.RunCommand(kubectlExecutable(), args...)
 	if err != nil {
 		log.Fatalf("run kubectl kustomize failed error: %s reason: %s", err, stdErr)
 	}
-	return stdOut
+	return helm.removeReleaseAnnotations(stdOut)
 }
 
-func prepareKustomizeFile(kustomizeFile string, resourcesFile string, workDir string) {
+func prepareKustomizeFile(kustomizeFile string, helm *helmOutput, data *template.Data) {
+	kustomizeFileYaml := prepareKustomization(kustomizeFile, helm, data, map[string]bool{})
 
-	// backup kustomize file
-	backupFile(kustomizeFile)
-	// evaluate templates
-	evaluateTemplates(kustomizeFile)
+	// add helm charts not referenced explicitly to kustomize file
+	kustomizeResources := listField(kustomizeFile, kustomizeFileYaml, "resources")
+	withCharts := helm.addResources(kustomizeFile, kustomizeResources)
+	if len(withCharts) != len(kustomizeResources) {
+		kustomizeFileYaml["resources"] = withCharts
+		writeKustomization(kustomizeFile, kustomizeFileYaml)
+	}
+	helm.write()
+}
+
+// prepareKustomization evaluates templates of kustomization file and all local files it refers to.
+// Nested kustomization and component dirs are prepared recursively. Each file is evaluated only once.
+// References helm://<releaseName> are replaced by paths of rendered charts.
+// Returns parsed evaluated kustomization.
+func prepareKustomization(kustomizeFile string, helm *helmOutput, data *template.Data, prepared map[string]bool) map[string]any {
+	prepareFile(kustomizeFile, data, prepared)
 
-	// add resources to kustomize file
 	var kustomizeFileYaml map[string]any
 	fileContent, err := os.ReadFile(kustomizeFile)
 	if err != nil {
 		log.Fatalf("reading kustomization file %q failed error: %s", kustomizeFile, err)
 	}
-
 	err = yaml.Unmarshal(fileContent, &kustomizeFileYaml)
 	if err != nil {
 		log.Fatalf("unmarshaling kustomize file %q failed error: %s", kustomizeFile, err)
 	}
-	res, ok := kustomizeFileYaml["resources"]
-	if !ok {
-		res = []any{}
-	}
-	kustomizeResources, err := unwrapResources(res)
-	if err != nil {
-		log.Fatalf("unwraping resources from %q failed error: %s", kustomizeFile, err)
+	if kustomizeFileYaml == nil {
+		kustomizeFileYaml = map[string]any{}
 	}
 
-	for _, resourceFile := range kustomizeResources {
-		if !strings.HasPrefix(resourceFile, "http") {
-			backupFile(resourceFile)
-			evaluateTemplates(resourceFile)
+	baseDir := filepath.Dir(kustomizeFile)
+	for _, key := range []string{"resources", "components"} {
+		for _, resource := range listField(kustomizeFile, kustomizeFileYaml, key) {
+			prepareResource(baseDir, resource, helm, data, prepared)
+		}
+	}
+	for _, patchFile := range listField(kustomizeFile, kustomizeFileYaml, "patchesStrategicMerge") {
+		// patchesStrategicMerge may contain inline patches
+		if !strings.Contains(patchFile, "\n") {
+			prepareReferencedFile(baseDir, patchFile, data, prepared)
+		}
+	}
+	for _, key := range []string{"patches", "replacements"} {
+		for _, item := range mapItems(kustomizeFile, kustomizeFileYaml, key) {
+			if path, ok := item["path"].(string); ok {
+				prepareReferencedFile(baseDir, path, data, prepared)
+			}
+		}
+	}
+	for _, key := range []string{"configMapGenerator", "secretGenerator"} {
+		for _, item := range mapItems(kustomizeFile, kustomizeFileYaml, key) {
+			for _, file := range generatorFiles(kustomizeFile, item) {
+				prepareReferencedFile(baseDir, file, data, prepared)
+			}
 		}
 	}
+	if helm.resolveRefs(kustomizeFile, kustomizeFileYaml) {
+		writeKustomization(kustomizeFile, kustomizeFileYaml)
+	}
+	return kustomizeFileYaml
+}
 
-	patches, ok := kustomizeFileYaml["patchesStrategicMerge"]
-	if !ok {
-		patches = []any{}
+func writeKustomization(kustomizeFile string, kustomizeFileYaml map[string]any) {
+	updatedFileContent, err := yaml.Marshal(kustomizeFileYaml)
+	if err != nil {
+		log.Fatalf("marshaling updated file content failed error: %s", err)
 	}
-	kustomizePatches, err := unwrapResources(patches)
+	err = os.WriteFile(kustomizeFile, updatedFileContent, os.ModePerm)
 	if err != nil {
-		log.Fatalf("unwraping patchesStrategicMerge from %q failed error: %s", kustomizeFile, err)
+		log.Fatalf("writing updated kustomize file %q failed error: %s", kustomizeFile, err)
 	}
-	for _, patchFile := range kustomizePatches {
-		if !strings.HasPrefix(patchFile, "http") {
-			backupFile(patchFile)
-			evaluateTemplates(patchFile)
-		}
+}
+
+// prepareResource prepares resource file or nested kustomization dir relative to base dir
+func prepareResource(baseDir string, resource string, helm *helmOutput, data *template.Data, prepared map[string]bool) {
+	if isRemote(resource) {
+		return
+	}
+	resource = filepath.Join(baseDir, resource)
+	info, err := os.Stat(resource)
+	if err != nil || !info.IsDir() {
+		prepareFile(resource, data, prepared)
+		return
+	}
+	nestedKustomizeFile := findKustomizeFileInDir(resource)
+	if nestedKustomizeFile == "" {
+		log.Fatalf("no kustomization file found in resource dir %q", resource)
+	}
+	if !prepared[nestedKustomizeFile] {
+		prepareKustomization(nestedKustomizeFile, helm, data, prepared)
 	}
+}
 
-	if resourcesFile != "" {
-		relativePath, err := filepath.Rel(workDir, resourcesFile)
-		if err != nil {
-			relativePath = resourcesFile
-		}
-		kustomizeResources = append(kustomizeResources, relativePath)
-		kustomizeFileYaml["resources"] = kustomizeResources
-		updatedFileContent, err := yaml.Marshal(kustomizeFileYaml)
-		if err != nil {
-			log.Fatalf("marshaling updated file content failed error: %s", err)
+// prepareReferencedFile prepares local file referenced by kustomization relative to base dir
+func prepareReferencedFile(baseDir string, file string, data *template.Data, prepared map[string]bool) {
+	if isRemote(file) {
+		return
+	}
+	prepareFile(filepath.Join(baseDir, file), data, prepared)
+}
+
+// prepareFile backups and evaluates templates of file unless it was already prepared
+func prepareFile(file string, data *template.Data, prepared map[string]bool) {
+	if prepared[file] {
+		return
+	}
+	prepared[file] = true
+	backupFile(file)
+	evaluateTemplates(file, data)
+}
+
+// generatorFiles returns files of configMapGenerator or secretGenerator. Files may be given as key=path.
+func generatorFiles(kustomizeFile string, generator map[string]any) []string {
+	var files []string
+	for _, file := range listField(kustomizeFile, generator, "files") {
+		if _, path, found := strings.Cut(file, "="); found {
+			file = path
 		}
-		err = os.WriteFile(kustomizeFile, updatedFileContent, os.ModePerm)
-		if err != nil {
-			log.Fatalf("writing updated kustomize file %q failed error: %s", kustomizeFile, err)
+		files = append(files, file)
+	}
+	files = append(files, listField(kustomizeFile, generator, "envs")...)
+	if env, ok := generator["env"].(string); ok && env != "" {
+		files = append(files, env)
+	}
+	return files
+}
+
+// listField returns list of strings under key of kustomization or empty list if key is missing
+func listField(kustomizeFile string, kustomizeFileYaml map[string]any, key string) []string {
+	value, ok := kustomizeFileYaml[key]
+	if !ok || value == nil {
+		return nil
+	}
+	list, err := unwrapResources(value)
+	if err != nil {
+		log.Fatalf("unwraping %s from %q failed error: %s", key, kustomizeFile, err)
+	}
+	return list
+}
+
+// mapItems returns list of objects under key of kustomization or empty list if key is missing
+func mapItems(kustomizeFile string, kustomizeFileYaml map[string]any, key string) []map[string]any {
+	value, ok := kustomizeFileYaml[key]
+	if !ok || value == nil {
+		return nil
+	}
+	collection, ok := value.([]any)
+	if !ok {
+		log.Fatalf("unwraping %s from %q failed error: given data should be type of %T but was %T", key, kustomizeFile, []any{}, value)
+	}
+	items := make([]map[string]any, 0, len(collection))
+	for _, item := range collection {
+		itemMap, ok := item.(map[string]any)
+		if !ok {
+			log.Fatalf("unwraping %s from %q failed error: item of given data should be type of %T but was %T", key, kustomizeFile, map[string]any{}, item)
 		}
+		items = append(items, itemMap)
 	}
+	return items
+}
+
+// isRemote returns true for remote resources like urls or git repos which are not evaluated
+func isRemote(resource string) bool {
+	return strings.HasPrefix(resource, "http") || strings.Contains(resource, "://") || strings.Contains(resource, "github.com/")
 }
 
 func backupFile(kustomizeFile string) {
@@ -148,15 +266,15 @@ func backupFile(kustomizeFile string) {
 	}
 }
 
-func evaluateTemplates(kustomizeFile string) {
+func evaluateTemplates(kustomizeFile string, data *template.Data) {
 	// evaluate templates
 	fileContent, err := os.ReadFile(kustomizeFile)
 	if err != nil {
 		log.Fatalf("reading kustomization file %q failed error: %s", kustomizeFile, err)
 	}
-	evaluated, err := template.EvalGoTemplates(string(fileContent))
+	evaluated, err := template.EvalFileTemplates(string(fileContent), data.ForFile(kustomizeFile))
 	if err != nil {
-		log.Fatalf("template evaluation of result failed error: %s", err)
+		log.Fatalf("template evaluation of %q failed error: %s", kustomizeFile, err)
 	}
 	err = os.WriteFile(kustomizeFile, []byte(evaluated), os.ModePerm)
 	if err != nil {
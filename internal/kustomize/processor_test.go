@@ -1 +1,313 @@
 package kustomize
+
+import (
+	types "github.com/librucha/krmgen/internal"
+	"github.com/librucha/krmgen/internal/manifest"
+	"github.com/librucha/krmgen/internal/template"
+	cons "github.com/librucha/krmgen/internal/utils"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFindKustomizeFile(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name: "root preferred over nested",
+			files: map[string]string{
+				"kustomization.yaml":         "resources: [overlay]",
+				"overlay/kustomization.yaml": "resources: []",
+			},
+			want: "kustomization.yaml",
+		},
+		{
+			name:  "single nested",
+			files: map[string]string{"base/Kustomization": "resources: []"},
+			want:  "base/Kustomization",
+		},
+		{
+			name:  "none",
+			files: map[string]string{"deployment.yaml": "kind: Deployment"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workDir := t.TempDir()
+			writeFiles(t, workDir, tt.files)
+			want := ""
+			if tt.want != "" {
+				want = filepath.Join(workDir, filepath.FromSlash(tt.want))
+			}
+			if got := FindKustomizeFile(workDir); got != want {
+				t.Errorf("FindKustomizeFile() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func Test_prepareKustomizeFile(t *testing.T) {
+	workDir := t.TempDir()
+	writeFiles(t, workDir, map[string]string{
+		"kustomization.yaml": `resources:
+  - deployment.yaml
+  - overlay
+  - https://example.com/remote.yaml
+components:
+  - components/region
+patchesStrategicMerge:
+  - psm.yaml
+patches:
+  - path: patches/replicas.yaml
+replacements:
+  - path: replacements.yaml
+configMapGenerator:
+  - name: config
+    files:
+      - app.properties
+      - custom=config/custom.properties
+    envs:
+      - config/app.env
+secretGenerator:
+  - name: secret
+    env: secret.env
+`,
+		"deployment.yaml":                      "region: {{ .Vars.region }}",
+		"overlay/kustomization.yaml":           "resources:\n  - service.yaml\n  - ../deployment.yaml\n",
+		"overlay/service.yaml":                 "region: {{ .Vars.region }}",
+		"components/region/kustomization.yaml": "kind: Component\npatches:\n  - path: region.yaml\n",
+		"components/region/region.yaml":        "region: {{ .Vars.region }}",
+		"psm.yaml":                             "region: {{ .Vars.region }}",
+		"patches/replicas.yaml":                "region: {{ .Vars.region }}",
+		"replacements.yaml":                    "region: {{ .Vars.region }}",
+		"app.properties":                       "region={{ .Vars.region }}",
+		"config/custom.properties":             "region={{ .Vars.region }}",
+		"config/app.env":                       "REGION={{ .Vars.region }}",
+		"secret.env":                           "REGION={{ .Vars.region }}",
+	})
+	data := template.NewData(&types.Config{Vars: map[string]any{"region": "westeurope"}}, workDir)
+	kustomizeFile := filepath.Join(workDir, "kustomization.yaml")
+	helm := newHelmOutput(nil, kustomizeFile, []types.RenderedChart{{ReleaseName: "web", Manifest: "kind: Service\n"}})
+
+	prepareKustomizeFile(kustomizeFile, helm, data)
+
+	for _, file := range []string{
+		"deployment.yaml",
+		"overlay/service.yaml",
+		"components/region/region.yaml",
+		"psm.yaml",
+		"patches/replicas.yaml",
+		"replacements.yaml",
+		"app.properties",
+		"config/custom.properties",
+		"config/app.env",
+		"secret.env",
+	} {
+		content, err := os.ReadFile(filepath.Join(workDir, filepath.FromSlash(file)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(content), "westeurope") || strings.Contains(string(content), "{{") {
+			t.Errorf("file %s not evaluated: %s", file, content)
+		}
+	}
+	kustomization, _ := os.ReadFile(filepath.Join(workDir, "kustomization.yaml"))
+	if !strings.Contains(string(kustomization), "- krmgen-helm/charts.yaml") {
+		t.Errorf("helm output not added to kustomization: %s", kustomization)
+	}
+}
+
+func Test_prepareKustomizeFile_helmOutput(t *testing.T) {
+	charts := []types.RenderedChart{
+		{ReleaseName: "web", Manifest: "kind: Deployment\n"},
+		{ReleaseName: "db", Manifest: "kind: StatefulSet\n"},
+		{ReleaseName: "cache", Manifest: "kind: ConfigMap\n"},
+	}
+	tests := []struct {
+		name       string
+		config     *types.Kustomize
+		files      map[string]string
+		want       map[string]string
+		wantAbsent []string
+	}{
+		{
+			name:   "single file appended",
+			config: &types.Kustomize{HelmOutput: &types.HelmOutput{Mode: HelmOutputSingle}},
+			files:  map[string]string{"kustomization.yaml": "resources:\n  - service.yaml\n", "service.yaml": "kind: Service\n"},
+			want: map[string]string{
+				"kustomization.yaml":      "resources:\n    - service.yaml\n    - krmgen-helm/charts.yaml\n",
+				"krmgen-helm/charts.yaml": "kind: Deployment\nkind: StatefulSet\nkind: ConfigMap\n",
+			},
+		},
+		{
+			name:   "per chart prepended",
+			config: &types.Kustomize{HelmOutput: &types.HelmOutput{Mode: HelmOutputPerChart, Position: PositionPrepend}},
+			files:  map[string]string{"kustomization.yaml": "resources:\n  - service.yaml\n", "service.yaml": "kind: Service\n"},
+			want: map[string]string{
+				"kustomization.yaml":                "resources:\n    - krmgen-helm/web\n    - krmgen-helm/db\n    - krmgen-helm/cache\n    - service.yaml\n",
+				"krmgen-helm/db/resources.yaml":     "kind: StatefulSet\n",
+				"krmgen-helm/db/kustomization.yaml": "apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\nresources:\n    - resources.yaml\n",
+			},
+		},
+		{
+			name:   "explicit references",
+			config: &types.Kustomize{HelmOutput: &types.HelmOutput{Mode: HelmOutputPerChart}},
+			files: map[string]string{
+				"kustomization.yaml":         "resources:\n  - overlay\n",
+				"overlay/kustomization.yaml": "resources:\n  - helm://web\ncomponents:\n  - helm://db\npatches:\n  - path: patch.yaml\n    target:\n      kind: Deployment\n",
+				"overlay/patch.yaml":         "- op: add\n  path: /metadata/labels\n  value: {}\n",
+			},
+			want: map[string]string{
+				"kustomization.yaml":                          "resources:\n    - overlay\n    - krmgen-helm/cache\n",
+				"overlay/kustomization.yaml":                  "components:\n    - ../krmgen-helm/db/component\npatches:\n    - path: patch.yaml\n      target:\n        kind: Deployment\nresources:\n    - ../krmgen-helm/web\n",
+				"krmgen-helm/db/component/kustomization.yaml": "apiVersion: kustomize.config.k8s.io/v1alpha1\nkind: Component\nresources:\n    - resources.yaml\n",
+				"krmgen-helm/web/resources.yaml":              "kind: Deployment\n",
+			},
+		},
+		{
+			name:   "patch targets",
+			config: &types.Kustomize{HelmOutput: &types.HelmOutput{Mode: HelmOutputPerChart}},
+			files: map[string]string{
+				"kustomization.yaml": "resources:\n  - overlay\n",
+				"overlay/kustomization.yaml": "resources:\n  - helm://web\n  - helm://db\npatches:\n  - path: patch.yaml\n    target: helm://web\n" +
+					"  - path: patch.yaml\n    target:\n      kind: StatefulSet\n      annotationSelector: helm://db\n",
+				"overlay/patch.yaml": "- op: add\n  path: /metadata/labels\n  value: {}\n",
+			},
+			want: map[string]string{
+				"overlay/kustomization.yaml": "patches:\n    - path: patch.yaml\n      target:\n        annotationSelector: krmgen.io/helm-release=web\n" +
+					"    - path: patch.yaml\n      target:\n        annotationSelector: krmgen.io/helm-release=db\n        kind: StatefulSet\nresources:\n    - ../krmgen-helm/web\n    - ../krmgen-helm/db\n",
+				"krmgen-helm/web/resources.yaml":   "kind: Deployment\nmetadata:\n  annotations:\n    krmgen.io/helm-release: web\n",
+				"krmgen-helm/db/resources.yaml":    "kind: StatefulSet\nmetadata:\n  annotations:\n    krmgen.io/helm-release: db\n",
+				"krmgen-helm/cache/resources.yaml": "kind: ConfigMap\n",
+			},
+		},
+		{
+			name:       "not added",
+			config:     &types.Kustomize{HelmOutput: &types.HelmOutput{Position: PositionNone}},
+			files:      map[string]string{"kustomization.yaml": "resources:\n  - service.yaml\n", "service.yaml": "kind: Service\n"},
+			want:       map[string]string{"kustomization.yaml": "resources:\n  - service.yaml\n"},
+			wantAbsent: []string{"krmgen-helm"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workDir := t.TempDir()
+			writeFiles(t, workDir, tt.files)
+			kustomizeFile := filepath.Join(workDir, "kustomization.yaml")
+
+			prepareKustomizeFile(kustomizeFile, newHelmOutput(tt.config, kustomizeFile, charts), template.NewData(nil, workDir))
+
+			for file, want := range tt.want {
+				got, err := os.ReadFile(filepath.Join(workDir, filepath.FromSlash(file)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != want {
+					t.Errorf("file %s = %q, want %q", file, got, want)
+				}
+			}
+			for _, file := range tt.wantAbsent {
+				if _, err := os.Stat(filepath.Join(workDir, file)); err == nil {
+					t.Errorf("file %s should not exist", file)
+				}
+			}
+		})
+	}
+}
+
+// realKubectl uses kubectl installed in OS or skips the test
+func realKubectl(t *testing.T) {
+	kubectl, err := exec.LookPath("kubectl")
+	if err != nil {
+		t.Skip("kubectl executable not found in OS")
+	}
+	t.Setenv(cons.EnvKubectlExecutable, kubectl)
+}
+
+func TestBuildKustomize_helmOutputNestedOverlay(t *testing.T) {
+	realKubectl(t)
+	charts := []types.RenderedChart{
+		{ReleaseName: "web", Manifest: "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n"},
+		{ReleaseName: "api", Manifest: "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: api\n"},
+		{ReleaseName: "db", Manifest: "apiVersion: apps/v1\nkind: StatefulSet\nmetadata:\n  name: db\n"},
+	}
+	workDir := t.TempDir()
+	writeFiles(t, workDir, map[string]string{
+		"kustomization.yaml":         "resources:\n  - overlay\n",
+		"overlay/kustomization.yaml": "resources:\n  - helm://web\n  - helm://api\ncomponents:\n  - helm://db\npatches:\n  - path: patch.yaml\n    target:\n      kind: Deployment\n      annotationSelector: helm://web\n",
+		"overlay/patch.yaml":         "- op: add\n  path: /metadata/labels\n  value:\n    team: a\n",
+	})
+	config := &types.Config{Kustomize: &types.Kustomize{HelmOutput: &types.HelmOutput{Mode: HelmOutputPerChart}}}
+
+	resources, err := manifest.Parse(BuildKustomize(config, filepath.Join(workDir, "kustomization.yaml"), workDir, charts))
+	if err != nil {
+		t.Fatal(err)
+	}
+	labels := map[string]any{}
+	for _, resource := range resources {
+		if _, found := manifest.Annotations(resource)[HelmReleaseAnnotation]; found {
+			t.Errorf("BuildKustomize() resource %s keeps release annotation", manifest.Name(resource))
+		}
+		labels[manifest.Name(resource)] = manifest.Metadata(resource)["labels"]
+	}
+	want := map[string]any{"web": map[string]any{"team": "a"}, "api": nil, "db": nil}
+	if !reflect.DeepEqual(labels, want) {
+		t.Errorf("BuildKustomize() labels = %v, want %v", labels, want)
+	}
+}
+
+func Test_helmOutput_removeReleaseAnnotations(t *testing.T) {
+	output := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  annotations:
+    krmgen.io/helm-release: web
+  name: web
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  annotations:
+    krmgen.io/helm-release: api
+    owner: team-a
+  name: api
+`
+	want := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  annotations:
+    owner: team-a
+  name: api
+`
+	h := newHelmOutput(nil, filepath.Join(t.TempDir(), "kustomization.yaml"), nil)
+	if got := h.removeReleaseAnnotations(output); got != output {
+		t.Errorf("removeReleaseAnnotations() without targets = %q, want %q", got, output)
+	}
+	h.targeted["web"] = true
+	if got := h.removeReleaseAnnotations(output); got != want {
+		t.Errorf("removeReleaseAnnotations() = %q, want %q", got, want)
+	}
+}
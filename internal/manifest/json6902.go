@@ -0,0 +1,230 @@
+package manifest
+
+import (
+	"fmt"
+	types "github.com/librucha/krmgen/internal"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ApplyJson6902 applies RFC 6902 operations to resource in place
+func ApplyJson6902(resource map[string]any, operations []types.JsonPatchOperation) error {
+	var root any = resource
+	for _, operation := range operations {
+		var err error
+		switch operation.Op {
+		case "add":
+			root, err = addValue(root, operation.Path, deepCopy(operation.Value))
+		case "remove":
+			root, _, err = removeValue(root, operation.Path)
+		case "replace":
+			root, _, err = removeValue(root, operation.Path)
+			if err == nil {
+				root, err = addValue(root, operation.Path, deepCopy(operation.Value))
+			}
+		case "move":
+			var value any
+			root, value, err = removeValue(root, operation.From)
+			if err == nil {
+				root, err = addValue(root, operation.Path, value)
+			}
+		case "copy":
+			var value any
+			value, err = getValue(root, operation.From)
+			if err == nil {
+				root, err = addValue(root, operation.Path, deepCopy(value))
+			}
+		case "test":
+			var value any
+			value, err = getValue(root, operation.Path)
+			if err == nil && !reflect.DeepEqual(value, operation.Value) {
+				err = fmt.Errorf("test of %q failed value is %v", operation.Path, value)
+			}
+		default:
+			err = fmt.Errorf("unknown operation %q", operation.Op)
+		}
+		if err != nil {
+			return fmt.Errorf("json patch operation %q on %q failed error: %s", operation.Op, operation.Path, err)
+		}
+	}
+	patched, ok := root.(map[string]any)
+	if !ok {
+		return fmt.Errorf("json patch replaced resource by %T", root)
+	}
+	if reflect.ValueOf(patched).Pointer() != reflect.ValueOf(resource).Pointer() {
+		for k := range resource {
+			delete(resource, k)
+		}
+		for k, v := range patched {
+			resource[k] = v
+		}
+	}
+	return nil
+}
+
+func parsePointer(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("json pointer %q must start with /", path)
+	}
+	tokens := strings.Split(path[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+func getValue(root any, path string) (any, error) {
+	tokens, err := parsePointer(path)
+	if err != nil {
+		return nil, err
+	}
+	current := root
+	for _, token := range tokens {
+		switch node := current.(type) {
+		case map[string]any:
+			value, found := node[token]
+			if !found {
+				return nil, fmt.Errorf("key %q not found", token)
+			}
+			current = value
+		case []any:
+			index, err := listIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			current = node[index]
+		default:
+			return nil, fmt.Errorf("cannot traverse %T by %q", current, token)
+		}
+	}
+	return current, nil
+}
+
+func addValue(root any, path string, value any) (any, error) {
+	tokens, err := parsePointer(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	parentPath := path[:strings.LastIndex(path, "/")]
+	parent, err := getValue(root, parentPath)
+	if err != nil {
+		return nil, err
+	}
+	last := tokens[len(tokens)-1]
+	switch node := parent.(type) {
+	case map[string]any:
+		node[last] = value
+		return root, nil
+	case []any:
+		index, err := listIndex(last, len(node), true)
+		if err != nil {
+			return nil, err
+		}
+		updated := append(node[:index:index], append([]any{value}, node[index:]...)...)
+		return setValue(root, parentPath, updated)
+	default:
+		return nil, fmt.Errorf("cannot add %q to %T", last, parent)
+	}
+}
+
+func removeValue(root any, path string) (any, any, error) {
+	tokens, err := parsePointer(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, root, nil
+	}
+	parentPath := path[:strings.LastIndex(path, "/")]
+	parent, err := getValue(root, parentPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	last := tokens[len(tokens)-1]
+	switch node := parent.(type) {
+	case map[string]any:
+		value, found := node[last]
+		if !found {
+			return nil, nil, fmt.Errorf("key %q not found", last)
+		}
+		delete(node, last)
+		return root, value, nil
+	case []any:
+		index, err := listIndex(last, len(node), false)
+		if err != nil {
+			return nil, nil, err
+		}
+		value := node[index]
+		updated := append(node[:index:index], node[index+1:]...)
+		root, err = setValue(root, parentPath, updated)
+		return root, value, err
+	default:
+		return nil, nil, fmt.Errorf("cannot remove %q from %T", last, parent)
+	}
+}
+
+// setValue replaces existing value at path. Used to write back lists resized by add or remove.
+func setValue(root any, path string, value any) (any, error) {
+	tokens, err := parsePointer(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	parent, err := getValue(root, path[:strings.LastIndex(path, "/")])
+	if err != nil {
+		return nil, err
+	}
+	last := tokens[len(tokens)-1]
+	switch node := parent.(type) {
+	case map[string]any:
+		node[last] = value
+	case []any:
+		index, err := listIndex(last, len(node), false)
+		if err != nil {
+			return nil, err
+		}
+		node[index] = value
+	default:
+		return nil, fmt.Errorf("cannot set %q of %T", last, parent)
+	}
+	return root, nil
+}
+
+func listIndex(token string, length int, allowEnd bool) (int, error) {
+	if token == "-" && allowEnd {
+		return length, nil
+	}
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || index > length || (index == length && !allowEnd) {
+		return 0, fmt.Errorf("list index %q out of range", token)
+	}
+	return index, nil
+}
+
+func deepCopy(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		result := make(map[string]any, len(v))
+		for key, item := range v {
+			result[key] = deepCopy(item)
+		}
+		return result
+	case []any:
+		result := make([]any, len(v))
+		for i, item := range v {
+			result[i] = deepCopy(item)
+		}
+		return result
+	default:
+		return v
+	}
+}
@@ -0,0 +1,129 @@
+package manifest
+
+import (
+	types "github.com/librucha/krmgen/internal"
+	"reflect"
+	"testing"
+)
+
+func TestApplyJson6902(t *testing.T) {
+	newResource := func() map[string]any {
+		return map[string]any{
+			"kind":     "Deployment",
+			"metadata": map[string]any{"name": "app", "labels": map[string]any{"app.kubernetes.io/name": "app"}},
+			"spec":     map[string]any{"replicas": 1, "args": []any{"--a", "--b"}},
+		}
+	}
+	tests := []struct {
+		name       string
+		operations []types.JsonPatchOperation
+		want       map[string]any
+		wantErr    bool
+	}{
+		{
+			name:       "replace scalar",
+			operations: []types.JsonPatchOperation{{Op: "replace", Path: "/spec/replicas", Value: 3}},
+			want: map[string]any{
+				"kind":     "Deployment",
+				"metadata": map[string]any{"name": "app", "labels": map[string]any{"app.kubernetes.io/name": "app"}},
+				"spec":     map[string]any{"replicas": 3, "args": []any{"--a", "--b"}},
+			},
+		},
+		{
+			name: "add to list and escaped key",
+			operations: []types.JsonPatchOperation{
+				{Op: "add", Path: "/spec/args/-", Value: "--c"},
+				{Op: "add", Path: "/spec/args/0", Value: "--first"},
+				{Op: "add", Path: "/metadata/labels/app.kubernetes.io~1part-of", Value: "krmgen"},
+			},
+			want: map[string]any{
+				"kind":     "Deployment",
+				"metadata": map[string]any{"name": "app", "labels": map[string]any{"app.kubernetes.io/name": "app", "app.kubernetes.io/part-of": "krmgen"}},
+				"spec":     map[string]any{"replicas": 1, "args": []any{"--first", "--a", "--b", "--c"}},
+			},
+		},
+		{
+			name: "remove, move and copy",
+			operations: []types.JsonPatchOperation{
+				{Op: "remove", Path: "/spec/args/0"},
+				{Op: "copy", From: "/metadata/name", Path: "/spec/serviceName"},
+				{Op: "move", From: "/metadata/labels", Path: "/spec/labels"},
+			},
+			want: map[string]any{
+				"kind":     "Deployment",
+				"metadata": map[string]any{"name": "app"},
+				"spec":     map[string]any{"replicas": 1, "args": []any{"--b"}, "serviceName": "app", "labels": map[string]any{"app.kubernetes.io/name": "app"}},
+			},
+		},
+		{
+			name:       "successful test",
+			operations: []types.JsonPatchOperation{{Op: "test", Path: "/spec/replicas", Value: 1}},
+			want:       newResource(),
+		},
+		// Rainy scenarios
+		{
+			name:       "failed test",
+			operations: []types.JsonPatchOperation{{Op: "test", Path: "/spec/replicas", Value: 2}},
+			wantErr:    true,
+		},
+		{
+			name:       "remove missing key",
+			operations: []types.JsonPatchOperation{{Op: "remove", Path: "/spec/missing"}},
+			wantErr:    true,
+		},
+		{
+			name:       "index out of range",
+			operations: []types.JsonPatchOperation{{Op: "replace", Path: "/spec/args/5", Value: "x"}},
+			wantErr:    true,
+		},
+		{
+			name:       "unknown operation",
+			operations: []types.JsonPatchOperation{{Op: "merge", Path: "/spec"}},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resource := newResource()
+			err := ApplyJson6902(resource, tt.operations)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ApplyJson6902() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && !reflect.DeepEqual(resource, tt.want) {
+				t.Errorf("ApplyJson6902() got = %v, want %v", resource, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyJson6902_nestedLists(t *testing.T) {
+	resource := map[string]any{"spec": map[string]any{
+		"containers": []any{
+			map[string]any{"name": "app", "args": []any{"--a", "--b"}},
+			map[string]any{"name": "sidecar", "args": []any{"--x"}},
+		},
+		"matrix": []any{[]any{1, 2}, []any{3}},
+	}}
+	operations := []types.JsonPatchOperation{
+		{Op: "add", Path: "/spec/containers/0/args/-", Value: "--c"},
+		{Op: "remove", Path: "/spec/containers/1/args/0"},
+		{Op: "add", Path: "/spec/containers/1", Value: map[string]any{"name": "init"}},
+		{Op: "add", Path: "/spec/matrix/0/-", Value: 4},
+		{Op: "remove", Path: "/spec/matrix/1/0"},
+	}
+	want := map[string]any{"spec": map[string]any{
+		"containers": []any{
+			map[string]any{"name": "app", "args": []any{"--a", "--b", "--c"}},
+			map[string]any{"name": "init"},
+			map[string]any{"name": "sidecar", "args": []any{}},
+		},
+		"matrix": []any{[]any{1, 2, 4}, []any{}},
+	}}
+	if err := ApplyJson6902(resource, operations); err != nil {
+		t.Fatalf("ApplyJson6902() error = %v", err)
+	}
+	if !reflect.DeepEqual(resource, want) {
+		t.Errorf("ApplyJson6902() got = %v, want %v", resource, want)
+	}
+}
@@ -0,0 +1,121 @@
+package manifest
+
+import (
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"strings"
+)
+
+// Parse decodes multi document YAML into resources. Empty documents are skipped.
+func Parse(content string) ([]map[string]any, error) {
+	decoder := yaml.NewDecoder(strings.NewReader(content))
+	var resources []map[string]any
+	for {
+		var resource map[string]any
+		err := decoder.Decode(&resource)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decoding resources failed error: %s", err)
+		}
+		if resource != nil {
+			resources = append(resources, resource)
+		}
+	}
+	return resources, nil
+}
+
+// Format encodes resources into multi document YAML
+func Format(resources []map[string]any) (string, error) {
+	result := strings.Builder{}
+	encoder := yaml.NewEncoder(&result)
+	encoder.SetIndent(2)
+	for _, resource := range resources {
+		if err := encoder.Encode(resource); err != nil {
+			return "", err
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+	return result.String(), nil
+}
+
+// Kind returns kind of resource
+func Kind(resource map[string]any) string {
+	kind, _ := resource["kind"].(string)
+	return kind
+}
+
+// GroupVersion returns group and version of resource apiVersion
+func GroupVersion(resource map[string]any) (string, string) {
+	apiVersion, _ := resource["apiVersion"].(string)
+	group, version, found := strings.Cut(apiVersion, "/")
+	if !found {
+		return "", apiVersion
+	}
+	return group, version
+}
+
+// Metadata returns metadata map of resource. It is created if missing.
+func Metadata(resource map[string]any) map[string]any {
+	metadata, ok := resource["metadata"].(map[string]any)
+	if !ok {
+		metadata = map[string]any{}
+		resource["metadata"] = metadata
+	}
+	return metadata
+}
+
+// ReadMetadata returns metadata map of resource without modifying it. Nil if missing.
+func ReadMetadata(resource map[string]any) map[string]any {
+	metadata, _ := resource["metadata"].(map[string]any)
+	return metadata
+}
+
+// Name returns metadata.name of resource
+func Name(resource map[string]any) string {
+	name, _ := ReadMetadata(resource)["name"].(string)
+	return name
+}
+
+// Namespace returns metadata.namespace of resource
+func Namespace(resource map[string]any) string {
+	namespace, _ := ReadMetadata(resource)["namespace"].(string)
+	return namespace
+}
+
+// Annotations returns metadata.annotations of resource as strings
+func Annotations(resource map[string]any) map[string]string {
+	result := map[string]string{}
+	annotations, _ := ReadMetadata(resource)["annotations"].(map[string]any)
+	for k, v := range annotations {
+		result[k] = fmt.Sprint(v)
+	}
+	return result
+}
+
+// SetAnnotation sets metadata.annotations key of resource
+func SetAnnotation(resource map[string]any, key string, value string) {
+	metadata := Metadata(resource)
+	annotations, ok := metadata["annotations"].(map[string]any)
+	if !ok {
+		annotations = map[string]any{}
+		metadata["annotations"] = annotations
+	}
+	annotations[key] = value
+}
+
+// RemoveAnnotation deletes metadata.annotations key of resource
+func RemoveAnnotation(resource map[string]any, key string) {
+	annotations, ok := ReadMetadata(resource)["annotations"].(map[string]any)
+	if ok {
+		delete(annotations, key)
+		if len(annotations) == 0 {
+			delete(Metadata(resource), "annotations")
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package manifest
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"reflect"
+	"sigs.k8s.io/kustomize/kyaml/openapi"
+	kyaml "sigs.k8s.io/kustomize/kyaml/yaml"
+	"sigs.k8s.io/kustomize/kyaml/yaml/merge2"
+	"strings"
+)
+
+const patchDirective = "$patch"
+const retainKeysDirective = "$retainKeys"
+const setElementOrderPrefix = "$setElementOrder/"
+
+// IsDeletePatch returns true if patch requests removal of the target resource
+func IsDeletePatch(patch map[string]any) bool {
+	return patch[patchDirective] == "delete"
+}
+
+// ApplyStrategicMerge merges patch into resource in place by kubernetes strategic merge patch rules.
+// Lists are merged by merge keys of kubernetes OpenAPI schema like kustomize patchesStrategicMerge does
+// and directives $patch, $retainKeys and $setElementOrder are honored.
+func ApplyStrategicMerge(resource map[string]any, patch map[string]any) error {
+	group, version := GroupVersion(resource)
+	apiVersion := version
+	if group != "" {
+		apiVersion = group + "/" + version
+	}
+	schema := openapi.SchemaForResourceType(kyaml.TypeMeta{APIVersion: apiVersion, Kind: Kind(resource)})
+
+	patch = deepCopy(patch).(map[string]any)
+	var directives []directive
+	extractDirectives(patch, schema, nil, &directives)
+
+	patchYaml, err := yaml.Marshal(patch)
+	if err != nil {
+		return fmt.Errorf("marshaling strategic merge patch failed error: %w", err)
+	}
+	resourceYaml, err := yaml.Marshal(resource)
+	if err != nil {
+		return fmt.Errorf("marshaling resource failed error: %w", err)
+	}
+	merged, err := merge2.MergeStrings(string(patchYaml), string(resourceYaml), false, kyaml.MergeOptions{ListIncreaseDirection: kyaml.MergeOptionsListAppend})
+	if err != nil {
+		return fmt.Errorf("strategic merge of %s %q failed error: %w", Kind(resource), Name(resource), err)
+	}
+	result := map[string]any{}
+	if err := yaml.Unmarshal([]byte(merged), &result); err != nil {
+		return fmt.Errorf("unmarshaling merged resource failed error: %w", err)
+	}
+	for _, d := range directives {
+		d.apply(result)
+	}
+
+	for k := range resource {
+		delete(resource, k)
+	}
+	for k, v := range result {
+		resource[k] = v
+	}
+	return nil
+}
+
+// pathStep addresses map field or element of list merged by key
+type pathStep struct {
+	field string
+	// key and value select list element of field if key is set
+	key   string
+	value any
+}
+
+// directive is $retainKeys or $setElementOrder of patch applied to merged resource
+type directive struct {
+	path []pathStep
+	// retainKeys of map at path
+	retainKeys []string
+	// orderField is list of map at path ordered by order
+	orderField string
+	order      []any
+}
+
+// extractDirectives removes $retainKeys and $setElementOrder directives from patch which merge2 does not support
+func extractDirectives(patch map[string]any, schema *openapi.ResourceSchema, path []pathStep, directives *[]directive) {
+	for k, v := range patch {
+		switch {
+		case k == retainKeysDirective:
+			var keys []string
+			for _, key := range asList(v) {
+				keys = append(keys, fmt.Sprint(key))
+			}
+			*directives = append(*directives, directive{path: path, retainKeys: keys})
+			delete(patch, k)
+		case strings.HasPrefix(k, setElementOrderPrefix):
+			*directives = append(*directives, directive{path: path, orderField: strings.TrimPrefix(k, setElementOrderPrefix), order: asList(v)})
+			delete(patch, k)
+		}
+	}
+	for k, v := range patch {
+		fieldSchema := fieldSchema(schema, k)
+		switch value := v.(type) {
+		case map[string]any:
+			extractDirectives(value, fieldSchema, appendStep(path, pathStep{field: k}), directives)
+		case []any:
+			key := mergeKey(fieldSchema)
+			if key == "" {
+				continue
+			}
+			elementSchema := fieldSchema.Elements()
+			for _, item := range value {
+				if element, ok := item.(map[string]any); ok {
+					extractDirectives(element, elementSchema, appendStep(path, pathStep{field: k, key: key, value: element[key]}), directives)
+				}
+			}
+		}
+	}
+}
+
+func (d directive) apply(resource map[string]any) {
+	target := resolvePath(resource, d.path)
+	if target == nil {
+		return
+	}
+	if d.retainKeys != nil {
+		for k := range target {
+			if !contains(d.retainKeys, k) {
+				delete(target, k)
+			}
+		}
+		return
+	}
+	list, ok := target[d.orderField].([]any)
+	if !ok {
+		return
+	}
+	target[d.orderField] = orderElements(list, d.order)
+}
+
+// orderElements returns elements of list in order first and the rest after them
+func orderElements(list []any, order []any) []any {
+	ordered := make([]any, 0, len(list))
+	used := make([]bool, len(list))
+	for _, wanted := range order {
+		for i, item := range list {
+			if !used[i] && matchesOrderItem(item, wanted) {
+				ordered = append(ordered, item)
+				used[i] = true
+				break
+			}
+		}
+	}
+	for i, item := range list {
+		if !used[i] {
+			ordered = append(ordered, item)
+		}
+	}
+	return ordered
+}
+
+// matchesOrderItem compares list element to $setElementOrder item which is merge key map or primitive value
+func matchesOrderItem(item any, wanted any) bool {
+	wantedMap, ok := wanted.(map[string]any)
+	if !ok {
+		return reflect.DeepEqual(item, wanted)
+	}
+	itemMap, ok := item.(map[string]any)
+	if !ok {
+		return false
+	}
+	for k, v := range wantedMap {
+		if !reflect.DeepEqual(itemMap[k], v) {
+			return false
+		}
+	}
+	return true
+}
+
+func resolvePath(resource map[string]any, path []pathStep) map[string]any {
+	current := resource
+	for _, step := range path {
+		if step.key == "" {
+			next, ok := current[step.field].(map[string]any)
+			if !ok {
+				return nil
+			}
+			current = next
+			continue
+		}
+		var next map[string]any
+		list, _ := current[step.field].([]any)
+		for _, item := range list {
+			if element, ok := item.(map[string]any); ok && reflect.DeepEqual(element[step.key], step.value) {
+				next = element
+				break
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		current = next
+	}
+	return current
+}
+
+func fieldSchema(schema *openapi.ResourceSchema, field string) *openapi.ResourceSchema {
+	if schema.IsMissingOrNull() {
+		return nil
+	}
+	return schema.Field(field)
+}
+
+// mergeKey returns merge key of list field or empty string if list is not merged by key
+func mergeKey(schema *openapi.ResourceSchema) string {
+	if schema.IsMissingOrNull() {
+		return ""
+	}
+	_, key := schema.PatchStrategyAndKey()
+	return key
+}
+
+func appendStep(path []pathStep, step pathStep) []pathStep {
+	return append(append([]pathStep{}, path...), step)
+}
+
+func asList(value any) []any {
+	list, _ := value.([]any)
+	return list
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
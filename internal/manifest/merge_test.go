@@ -0,0 +1,122 @@
+package manifest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApplyStrategicMerge(t *testing.T) {
+	deployment := func(spec map[string]any) map[string]any {
+		return map[string]any{
+			"apiVersion": "apps/v1",
+			"kind":       "Deployment",
+			"metadata":   map[string]any{"name": "app"},
+			"spec":       map[string]any{"template": map[string]any{"spec": spec}},
+		}
+	}
+	tests := []struct {
+		name     string
+		resource map[string]any
+		patch    map[string]any
+		want     map[string]any
+	}{
+		{
+			name:     "merge maps and delete by null",
+			resource: map[string]any{"metadata": map[string]any{"name": "app", "labels": map[string]any{"a": "1", "b": "2"}}},
+			patch:    map[string]any{"metadata": map[string]any{"labels": map[string]any{"a": nil, "c": "3"}}},
+			want:     map[string]any{"metadata": map[string]any{"name": "app", "labels": map[string]any{"b": "2", "c": "3"}}},
+		},
+		{
+			name: "merge containers by name",
+			resource: deployment(map[string]any{"containers": []any{
+				map[string]any{"name": "app", "image": "app:1"},
+				map[string]any{"name": "sidecar", "image": "sidecar:1"},
+			}}),
+			patch: deployment(map[string]any{"containers": []any{
+				map[string]any{"name": "app", "image": "app:2"},
+				map[string]any{"name": "sidecar", "$patch": "delete"},
+				map[string]any{"name": "extra", "image": "extra:1"},
+			}}),
+			want: deployment(map[string]any{"containers": []any{
+				map[string]any{"name": "app", "image": "app:2"},
+				map[string]any{"name": "extra", "image": "extra:1"},
+			}}),
+		},
+		{
+			name: "merge by schema keys",
+			resource: deployment(map[string]any{"containers": []any{map[string]any{
+				"name":         "app",
+				"ports":        []any{map[string]any{"containerPort": 80, "protocol": "TCP"}},
+				"volumeMounts": []any{map[string]any{"mountPath": "/data", "name": "data"}},
+			}}}),
+			patch: deployment(map[string]any{"containers": []any{map[string]any{
+				"name":         "app",
+				"ports":        []any{map[string]any{"containerPort": 80, "protocol": "TCP", "name": "http"}},
+				"volumeMounts": []any{map[string]any{"mountPath": "/data", "name": "cache"}},
+			}}}),
+			want: deployment(map[string]any{"containers": []any{map[string]any{
+				"name":         "app",
+				"ports":        []any{map[string]any{"containerPort": 80, "name": "http", "protocol": "TCP"}},
+				"volumeMounts": []any{map[string]any{"mountPath": "/data", "name": "cache"}},
+			}}}),
+		},
+		{
+			name: "replace list by patch directive",
+			resource: deployment(map[string]any{"containers": []any{
+				map[string]any{"name": "app", "image": "app:1"},
+				map[string]any{"name": "sidecar", "image": "sidecar:1"},
+			}}),
+			patch: deployment(map[string]any{"containers": []any{
+				map[string]any{"name": "other", "image": "other:1"},
+				map[string]any{"$patch": "replace"},
+			}}),
+			want: deployment(map[string]any{"containers": []any{
+				map[string]any{"name": "other", "image": "other:1"},
+			}}),
+		},
+		{
+			name: "retain keys",
+			resource: deployment(map[string]any{"volumes": []any{
+				map[string]any{"name": "data", "emptyDir": map[string]any{}},
+			}}),
+			patch: deployment(map[string]any{"volumes": []any{
+				map[string]any{"name": "data", "hostPath": map[string]any{"path": "/data"}, "$retainKeys": []any{"name", "hostPath"}},
+			}}),
+			want: deployment(map[string]any{"volumes": []any{
+				map[string]any{"name": "data", "hostPath": map[string]any{"path": "/data"}},
+			}}),
+		},
+		{
+			name: "set element order",
+			resource: deployment(map[string]any{"containers": []any{
+				map[string]any{"name": "a", "image": "a"},
+				map[string]any{"name": "b", "image": "b"},
+			}}),
+			patch: deployment(map[string]any{
+				"$setElementOrder/containers": []any{map[string]any{"name": "c"}, map[string]any{"name": "b"}, map[string]any{"name": "a"}},
+				"containers":                  []any{map[string]any{"name": "c", "image": "c"}},
+			}),
+			want: deployment(map[string]any{"containers": []any{
+				map[string]any{"name": "c", "image": "c"},
+				map[string]any{"name": "b", "image": "b"},
+				map[string]any{"name": "a", "image": "a"},
+			}}),
+		},
+		{
+			name:     "replace scalar lists",
+			resource: deployment(map[string]any{"containers": []any{map[string]any{"name": "app", "args": []any{"--a", "--b"}}}}),
+			patch:    deployment(map[string]any{"containers": []any{map[string]any{"name": "app", "args": []any{"--c"}}}}),
+			want:     deployment(map[string]any{"containers": []any{map[string]any{"name": "app", "args": []any{"--c"}}}}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ApplyStrategicMerge(tt.resource, tt.patch); err != nil {
+				t.Fatalf("ApplyStrategicMerge() error = %v", err)
+			}
+			if !reflect.DeepEqual(tt.resource, tt.want) {
+				t.Errorf("ApplyStrategicMerge() got = %v, want %v", tt.resource, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package manifest
+
+import (
+	"sigs.k8s.io/kustomize/kyaml/openapi"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// clusterScopedKinds are well known kinds without namespace
+var clusterScopedKinds = map[string]any{
+	"APIService":                     nil,
+	"CertificateSigningRequest":      nil,
+	"ClusterIssuer":                  nil,
+	"ClusterRole":                    nil,
+	"ClusterRoleBinding":             nil,
+	"CSIDriver":                      nil,
+	"CSINode":                        nil,
+	"CustomResourceDefinition":       nil,
+	"IngressClass":                   nil,
+	"MutatingWebhookConfiguration":   nil,
+	"Namespace":                      nil,
+	"Node":                           nil,
+	"PersistentVolume":               nil,
+	"PodSecurityPolicy":              nil,
+	"PriorityClass":                  nil,
+	"RuntimeClass":                   nil,
+	"StorageClass":                   nil,
+	"ValidatingWebhookConfiguration": nil,
+	"VolumeAttachment":               nil,
+	"VolumeSnapshotClass":            nil,
+}
+
+// SetNamespace forces namespace of all namespaced resources. Kinds in extraClusterScopedKinds are cluster scoped too.
+// Resource without namespace of kind unknown to Kubernetes OpenAPI may be cluster scoped custom resource so it is skipped.
+// ServiceAccount subjects of role bindings pointing to rewritten namespaces are updated too.
+func SetNamespace(resources []map[string]any, namespace string, extraClusterScopedKinds []string) {
+	rewritten := map[string]any{}
+	for _, resource := range resources {
+		if !isNamespaced(resource, extraClusterScopedKinds) {
+			continue
+		}
+		rewritten[Namespace(resource)] = nil
+		Metadata(resource)["namespace"] = namespace
+	}
+	for _, resource := range resources {
+		kind := Kind(resource)
+		if kind != "RoleBinding" && kind != "ClusterRoleBinding" {
+			continue
+		}
+		subjects, _ := resource["subjects"].([]any)
+		for _, item := range subjects {
+			subject, ok := item.(map[string]any)
+			if !ok || subject["kind"] != "ServiceAccount" {
+				continue
+			}
+			subjectNamespace, _ := subject["namespace"].(string)
+			if _, found := rewritten[subjectNamespace]; found {
+				subject["namespace"] = namespace
+			}
+		}
+	}
+}
+
+// isNamespaced returns true if resource is known or expected to be namespaced
+func isNamespaced(resource map[string]any, extraClusterScopedKinds []string) bool {
+	kind := Kind(resource)
+	if _, clusterScoped := clusterScopedKinds[kind]; clusterScoped || contains(extraClusterScopedKinds, kind) {
+		return false
+	}
+	apiVersion, _ := resource["apiVersion"].(string)
+	namespaced, known := openapi.IsNamespaceScoped(yaml.TypeMeta{APIVersion: apiVersion, Kind: kind})
+	if known {
+		return namespaced
+	}
+	return Namespace(resource) != ""
+}
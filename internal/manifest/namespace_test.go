@@ -0,0 +1,54 @@
+package manifest
+
+import (
+	"testing"
+)
+
+func TestSetNamespace(t *testing.T) {
+	resource := func(apiVersion string, kind string, namespace string) map[string]any {
+		metadata := map[string]any{"name": "app"}
+		if namespace != "" {
+			metadata["namespace"] = namespace
+		}
+		return map[string]any{"apiVersion": apiVersion, "kind": kind, "metadata": metadata}
+	}
+	tests := []struct {
+		name                    string
+		resource                map[string]any
+		extraClusterScopedKinds []string
+		want                    string
+	}{
+		{
+			name:     "known namespaced kind without namespace",
+			resource: resource("apps/v1", "Deployment", ""),
+			want:     "team-a",
+		},
+		{
+			name:     "known cluster scoped kind",
+			resource: resource("rbac.authorization.k8s.io/v1", "ClusterRole", ""),
+		},
+		{
+			name:     "custom resource with namespace",
+			resource: resource("cert-manager.io/v1", "Certificate", "default"),
+			want:     "team-a",
+		},
+		{
+			name:     "custom resource without namespace",
+			resource: resource("example.com/v1", "ClusterPolicy", ""),
+		},
+		{
+			name:                    "extra cluster scoped kind with namespace",
+			resource:                resource("example.com/v1", "ClusterPolicy", "default"),
+			extraClusterScopedKinds: []string{"ClusterPolicy"},
+			want:                    "default",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetNamespace([]map[string]any{tt.resource}, "team-a", tt.extraClusterScopedKinds)
+			if got := Namespace(tt.resource); got != tt.want {
+				t.Errorf("SetNamespace() namespace = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package manifest
+
+import (
+	types "github.com/librucha/krmgen/internal"
+)
+
+// Matches returns true if resource matches all non-empty fields of selector
+func Matches(resource map[string]any, selector *types.ResourceSelector) bool {
+	if selector == nil {
+		return true
+	}
+	group, version := GroupVersion(resource)
+	return matchField(selector.Group, group) &&
+		matchField(selector.Version, version) &&
+		matchField(selector.Kind, Kind(resource)) &&
+		matchField(selector.Name, Name(resource)) &&
+		matchField(selector.Namespace, Namespace(resource))
+}
+
+func matchField(expected string, actual string) bool {
+	return expected == "" || expected == actual
+}
@@ -0,0 +1,42 @@
+package manifest
+
+import (
+	types "github.com/librucha/krmgen/internal"
+	"reflect"
+	"testing"
+)
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		resource map[string]any
+		selector *types.ResourceSelector
+		want     bool
+	}{
+		{
+			name:     "matching name and namespace",
+			resource: map[string]any{"apiVersion": "v1", "kind": "Service", "metadata": map[string]any{"name": "app", "namespace": "default"}},
+			selector: &types.ResourceSelector{Kind: "Service", Name: "app", Namespace: "default"},
+			want:     true,
+		},
+		{
+			name:     "resource without metadata is not modified",
+			resource: map[string]any{"apiVersion": "v1", "kind": "List"},
+			selector: &types.ResourceSelector{Name: "app"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := map[string]any{}
+			for k, v := range tt.resource {
+				original[k] = v
+			}
+			if got := Matches(tt.resource, tt.selector); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(tt.resource, original) {
+				t.Errorf("Matches() modified resource to %v", tt.resource)
+			}
+		})
+	}
+}
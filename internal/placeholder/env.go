@@ -0,0 +1,82 @@
+package placeholder
+
+import (
+	"fmt"
+	"github.com/librucha/krmgen/internal/template/argocd"
+	"github.com/librucha/krmgen/internal/template/kube"
+	cons "github.com/librucha/krmgen/internal/utils"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// envPattern matches ${env:NAME} and ${env:NAME:default}. Leading $ escapes the placeholder.
+var envPattern = regexp.MustCompile(`(\$?)\$\s*\{env:([^:}]+)(?::(.*?))?\}`)
+
+var defaultEnvPrefixes = []string{argocd.EnvEnvKeyPrefix, argocd.EnvAppKeyPrefix, kube.EnvKeyPrefix}
+
+// EvalEnvPlaceholders replaces ${env:NAME:default} placeholders by env values.
+// Only env names with allowed prefix are read. Placeholder of not allowed env resolves to its default
+// and fails only without default.
+func EvalEnvPlaceholders(content string) (string, error) {
+	matches := envPattern.FindAllStringSubmatchIndex(content, -1)
+	if len(matches) == 0 {
+		return content, nil
+	}
+	allowed := allowedEnvPrefixes()
+	result := strings.Builder{}
+	last := 0
+	for _, m := range matches {
+		result.WriteString(content[last:m[0]])
+		last = m[1]
+		match := content[m[0]:m[1]]
+		// escaped placeholder
+		if m[3] > m[2] {
+			result.WriteString(match[1:])
+			continue
+		}
+		name := strings.TrimSpace(content[m[4]:m[5]])
+		if !isAllowedEnv(name, allowed) {
+			if m[6] < 0 {
+				return "", fmt.Errorf("env %q is not allowed in placeholder %q without default value. Allowed prefixes are %v", name, match, allowed)
+			}
+			result.WriteString(content[m[6]:m[7]])
+			continue
+		}
+		value, found := os.LookupEnv(name)
+		if !found {
+			if m[6] < 0 {
+				return "", fmt.Errorf("env %q of placeholder %q not found in env and default value not provided", name, match)
+			}
+			value = content[m[6]:m[7]]
+		}
+		result.WriteString(value)
+	}
+	result.WriteString(content[last:])
+	return result.String(), nil
+}
+
+// allowedEnvPrefixes returns prefixes from env or default ArgoCD prefixes
+func allowedEnvPrefixes() []string {
+	value, found := os.LookupEnv(cons.EnvPlaceholderEnvPrefixes)
+	if !found {
+		return defaultEnvPrefixes
+	}
+	var prefixes []string
+	for _, prefix := range strings.Split(value, ",") {
+		prefix = strings.TrimSpace(prefix)
+		if prefix != "" {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	return prefixes
+}
+
+func isAllowedEnv(name string, allowed []string) bool {
+	for _, prefix := range allowed {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package placeholder
+
+import (
+	cons "github.com/librucha/krmgen/internal/utils"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestEvalEnvPlaceholders(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		env     map[string]string
+		want    string
+		wantErr bool
+	}{
+		{
+			name:    "no placeholder",
+			content: "key: value",
+			want:    "key: value",
+		},
+		{
+			name:    "existing env",
+			content: "namespace: ${env:ARGOCD_APP_NAMESPACE}",
+			env:     map[string]string{"ARGOCD_APP_NAMESPACE": "team-a"},
+			want:    "namespace: team-a",
+		},
+		{
+			name:    "existing env with default",
+			content: "region: ${env:ARGOCD_ENV_REGION:westeurope}",
+			env:     map[string]string{"ARGOCD_ENV_REGION": "northeurope"},
+			want:    "region: northeurope",
+		},
+		{
+			name:    "missing env with default",
+			content: "region: ${env:ARGOCD_ENV_REGION:westeurope}",
+			want:    "region: westeurope",
+		},
+		{
+			name:    "missing env with empty default",
+			content: "region: '${env:ARGOCD_ENV_REGION:}'",
+			want:    "region: ''",
+		},
+		{
+			name:    "multiple placeholders",
+			content: "${env:KUBE_VERSION:1.25}/${env:ARGOCD_ENV_REGION:westeurope}",
+			env:     map[string]string{"KUBE_VERSION": "1.27"},
+			want:    "1.27/westeurope",
+		},
+		{
+			name:    "escaped placeholder",
+			content: "value: $${env:ARGOCD_ENV_REGION}",
+			want:    "value: ${env:ARGOCD_ENV_REGION}",
+		},
+		{
+			name:    "go template is untouched",
+			content: `value: {{ .Values.region }}`,
+			want:    `value: {{ .Values.region }}`,
+		},
+		{
+			name:    "not allowed env resolves to default",
+			content: "path: '${env:PWD:/default}'",
+			env:     map[string]string{"PWD": "/work"},
+			want:    "path: '/default'",
+		},
+		{
+			name:    "custom allowed prefixes",
+			content: "path: ${env:PWD}",
+			env:     map[string]string{cons.EnvPlaceholderEnvPrefixes: "PW, HOME", "PWD": "/work"},
+			want:    "path: /work",
+		},
+		// Rainy scenarios
+		{
+			name:    "missing env without default",
+			content: "region: ${env:ARGOCD_ENV_REGION}",
+			wantErr: true,
+		},
+		{
+			name:    "not allowed env without default",
+			content: "path: ${env:PWD}",
+			env:     map[string]string{"PWD": "/work"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				_ = os.Setenv(k, v)
+			}
+			got, err := EvalEnvPlaceholders(tt.content)
+			for k := range tt.env {
+				_ = os.Unsetenv(k)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("EvalEnvPlaceholders() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("EvalEnvPlaceholders() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvalEnvPlaceholders_allowlist(t *testing.T) {
+	content, err := os.ReadFile("../../test/resources/full/kustomize/resources/cm.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{
+			name: "PWD not allowed by default prefixes resolves to default",
+			env:  map[string]string{"PWD": "/work"},
+			want: "variable: \n",
+		},
+		{
+			name: "PWD allowed by configured prefixes",
+			env:  map[string]string{"PWD": "/work", cons.EnvPlaceholderEnvPrefixes: "ARGOCD_ENV_,PWD"},
+			want: "variable: /work\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			got, err := EvalEnvPlaceholders(string(content))
+			if err != nil {
+				t.Fatalf("EvalEnvPlaceholders() error = %v", err)
+			}
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("EvalEnvPlaceholders() got = %v, want to contain %v", got, tt.want)
+			}
+		})
+	}
+}
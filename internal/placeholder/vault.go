@@ -0,0 +1,130 @@
+package placeholder
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	azsec "github.com/librucha/krmgen/internal/template/azure/sec"
+	"gopkg.in/yaml.v3"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// vaultPattern matches argocd-vault-plugin placeholders <path:vault#key> and <path:vault#key#version>
+var vaultPattern = regexp.MustCompile(`<path:([^#<>\s]+)#([^#<>\s]+)(?:#([^#<>\s]+))?>`)
+
+// EvalVaultPlaceholders replaces argocd-vault-plugin placeholders in rendered resources by Azure Key Vault secrets.
+// Values under data of Secret resources are base64 encoded.
+func EvalVaultPlaceholders(resources string) (string, error) {
+	if !vaultPattern.MatchString(resources) {
+		return resources, nil
+	}
+	decoder := yaml.NewDecoder(strings.NewReader(resources))
+	var docs []*yaml.Node
+	for {
+		var doc yaml.Node
+		err := decoder.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("decoding resources for vault placeholders failed error: %s", err)
+		}
+		if err := replaceVaultPlaceholders(&doc, isSecret(&doc)); err != nil {
+			return "", err
+		}
+		docs = append(docs, &doc)
+	}
+
+	result := strings.Builder{}
+	encoder := yaml.NewEncoder(&result)
+	encoder.SetIndent(2)
+	for _, doc := range docs {
+		if err := encoder.Encode(doc); err != nil {
+			return "", err
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+	return result.String(), nil
+}
+
+func replaceVaultPlaceholders(node *yaml.Node, secret bool) error {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			// only the document root can be a Secret
+			if err := replaceVaultPlaceholders(child, secret && node.Kind == yaml.DocumentNode); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if secret && key.Value == "data" && value.Kind == yaml.MappingNode {
+				if err := replaceSecretData(value); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := replaceVaultPlaceholders(value, false); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		replaced, err := replaceInText(node.Value)
+		if err != nil {
+			return err
+		}
+		node.Value = replaced
+	}
+	return nil
+}
+
+func replaceSecretData(data *yaml.Node) error {
+	for i := 1; i < len(data.Content); i += 2 {
+		value := data.Content[i]
+		if value.Kind != yaml.ScalarNode || !vaultPattern.MatchString(value.Value) {
+			continue
+		}
+		replaced, err := replaceInText(value.Value)
+		if err != nil {
+			return err
+		}
+		value.Value = base64.StdEncoding.EncodeToString([]byte(replaced))
+		value.Style = 0
+	}
+	return nil
+}
+
+func replaceInText(text string) (string, error) {
+	var replaceErr error
+	result := vaultPattern.ReplaceAllStringFunc(text, func(match string) string {
+		if replaceErr != nil {
+			return match
+		}
+		groups := vaultPattern.FindStringSubmatch(match)
+		secret, err := azsec.GetSecret(groups[1], groups[2], groups[3])
+		if err != nil {
+			replaceErr = fmt.Errorf("resolving vault placeholder %q failed error: %s", match, err)
+			return match
+		}
+		return secret.(string)
+	})
+	return result, replaceErr
+}
+
+func isSecret(doc *yaml.Node) bool {
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return false
+	}
+	root := doc.Content[0]
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == "kind" && root.Content[i+1].Value == "Secret" {
+			return true
+		}
+	}
+	return false
+}
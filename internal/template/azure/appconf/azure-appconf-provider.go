@@ -0,0 +1,208 @@
+package azappconf
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	azsec "github.com/librucha/krmgen/internal/template/azure/sec"
+	"github.com/librucha/krmgen/internal/template/offline"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const AppConfFunc = "azAppConf"
+const FeatureFunc = "azFeature"
+
+const apiVersion = "1.0"
+const featureFlagPrefix = ".appconfig.featureflag/"
+const keyVaultRefContentType = "application/vnd.microsoft.appconfig.keyvaultref+json"
+
+type settingId string
+
+// setting is the key-value representation returned by App Configuration REST API
+type setting struct {
+	Key         string `json:"key"`
+	Label       string `json:"label"`
+	ContentType string `json:"content_type"`
+	Value       string `json:"value"`
+}
+
+type keyVaultRef struct {
+	Uri string `json:"uri"`
+}
+
+type featureFlag struct {
+	Id      string `json:"id"`
+	Enabled bool   `json:"enabled"`
+}
+
+type client struct {
+	endpoint string
+	pipeline runtime.Pipeline
+}
+
+var azureClients = make(map[string]*client, 10)
+
+var cachedSettings = make(map[settingId]*setting, 50)
+
+func GetAppConf(storeName string, keyArgs ...string) (any, error) {
+	switch len(keyArgs) {
+	case 1:
+		return getValueFromAzure(storeName, keyArgs[0], "")
+	case 2:
+		return getValueFromAzure(storeName, keyArgs[0], keyArgs[1])
+	default:
+		return nil, fmt.Errorf("wrong arguments count for function %q expected 1 or 2 aruments but got %d", AppConfFunc, len(keyArgs))
+	}
+}
+
+func GetFeature(storeName string, flagName string) (bool, error) {
+	if offline.Enabled() {
+		return offline.Resolve(FeatureFunc, storeName, flagName) == "true", nil
+	}
+	s, err := getSettingFromAzure(storeName, featureFlagPrefix+flagName, "")
+	if err != nil {
+		return false, err
+	}
+	var flag featureFlag
+	if err := json.Unmarshal([]byte(s.Value), &flag); err != nil {
+		return false, fmt.Errorf("feature flag %q in store %q is not valid error: %s", flagName, storeName, err)
+	}
+	return flag.Enabled, nil
+}
+
+func getValueFromAzure(storeName string, key string, label string) (string, error) {
+	if offline.Enabled() {
+		return offline.Resolve(AppConfFunc, storeName, key, label), nil
+	}
+	s, err := getSettingFromAzure(storeName, key, label)
+	if err != nil {
+		return "", err
+	}
+	if !isKeyVaultRef(s) {
+		return s.Value, nil
+	}
+	vaultName, secretName, secretVer, err := parseKeyVaultRef(s.Value)
+	if err != nil {
+		return "", fmt.Errorf("key vault reference %q in store %q is not valid error: %s", key, storeName, err)
+	}
+	secret, err := azsec.GetSecret(vaultName, secretName, secretVer)
+	if err != nil {
+		return "", err
+	}
+	return secret.(string), nil
+}
+
+func getSettingFromAzure(storeName string, key string, label string) (*setting, error) {
+	id := newId(storeName, key, label)
+	cached := getFromCache(id)
+	if cached != nil {
+		return cached, nil
+	}
+	c, err := getClient(storeName)
+	if err != nil {
+		return nil, err
+	}
+	s, err := c.getSetting(context.Background(), key, label)
+	if err != nil {
+		return nil, err
+	}
+	saveToCache(id, s)
+	return s, nil
+}
+
+func (c *client) getSetting(ctx context.Context, key string, label string) (*setting, error) {
+	req, err := runtime.NewRequest(ctx, http.MethodGet, runtime.JoinPaths(c.endpoint, "kv", url.PathEscape(key)))
+	if err != nil {
+		return nil, err
+	}
+	query := req.Raw().URL.Query()
+	query.Set("api-version", apiVersion)
+	if label != "" {
+		query.Set("label", label)
+	}
+	req.Raw().URL.RawQuery = query.Encode()
+	req.Raw().Header.Set("Accept", "application/vnd.microsoft.appconfig.kv+json")
+	resp, err := c.pipeline.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if !runtime.HasStatusCode(resp, http.StatusOK) {
+		return nil, runtime.NewResponseError(resp)
+	}
+	var s setting
+	if err := runtime.UnmarshalAsJSON(resp, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func getClient(storeName string) (*client, error) {
+	c := azureClients[storeName]
+	if c != nil {
+		return c, nil
+	}
+	cred, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		return nil, err
+	}
+	c = newClient(getStoreUrl(storeName), cred, nil)
+	azureClients[storeName] = c
+	return c, nil
+}
+
+func newClient(endpoint string, cred azcore.TokenCredential, options *policy.ClientOptions) *client {
+	authPolicy := runtime.NewBearerTokenPolicy(cred, []string{endpoint + "/.default"}, nil)
+	pipeline := runtime.NewPipeline("azappconf", "v1", runtime.PipelineOptions{PerRetry: []policy.Policy{authPolicy}}, options)
+	return &client{endpoint: endpoint, pipeline: pipeline}
+}
+
+// parseKeyVaultRef returns vault name, secret name and version from App Configuration Key Vault reference
+func parseKeyVaultRef(value string) (string, string, string, error) {
+	var ref keyVaultRef
+	if err := json.Unmarshal([]byte(value), &ref); err != nil {
+		return "", "", "", err
+	}
+	refUrl, err := url.Parse(ref.Uri)
+	if err != nil {
+		return "", "", "", err
+	}
+	vaultName, _, _ := strings.Cut(refUrl.Hostname(), ".")
+	segments := strings.Split(strings.Trim(refUrl.Path, "/"), "/")
+	if vaultName == "" || len(segments) < 2 || len(segments) > 3 || segments[0] != "secrets" {
+		return "", "", "", fmt.Errorf("unexpected secret uri %q", ref.Uri)
+	}
+	if len(segments) == 3 {
+		return vaultName, segments[1], segments[2], nil
+	}
+	return vaultName, segments[1], "", nil
+}
+
+func isKeyVaultRef(s *setting) bool {
+	return strings.HasPrefix(s.ContentType, keyVaultRefContentType)
+}
+
+func newId(storeName string, key string, label string) settingId {
+	return settingId(strings.Join([]string{getStoreUrl(storeName), key, label}, "/"))
+}
+
+func getStoreUrl(storeName string) string {
+	return fmt.Sprintf("https://%v.azconfig.io", storeName)
+}
+
+func getFromCache(id settingId) *setting {
+	cached := cachedSettings[id]
+	if cached == nil {
+		return nil
+	}
+	return cached
+}
+
+func saveToCache(id settingId, s *setting) {
+	cachedSettings[id] = s
+}
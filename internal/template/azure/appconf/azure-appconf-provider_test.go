@@ -0,0 +1,216 @@
+package azappconf
+
+import (
+	"context"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type mockSender struct {
+	doFunc func(r *http.Request) (*http.Response, error)
+}
+
+func (m mockSender) Do(r *http.Request) (*http.Response, error) {
+	return m.doFunc(r)
+}
+
+func TestGetAppConf(t *testing.T) {
+	type args struct {
+		storeName string
+		keyArgs   []string
+	}
+	tests := []struct {
+		name      string
+		args      args
+		resBody   string
+		resStatus int
+		wantQuery string
+		want      string
+		wantErr   bool
+	}{
+		{
+			name: "valid key",
+			args: args{
+				storeName: "store_name",
+				keyArgs:   []string{"app:region"},
+			},
+			resBody:   `{"key":"app:region","value":"westeurope"}`,
+			wantQuery: "api-version=1.0",
+			want:      "westeurope",
+			wantErr:   false,
+		},
+		{
+			name: "key with label",
+			args: args{
+				storeName: "store_name",
+				keyArgs:   []string{"app:region", "prod"},
+			},
+			resBody:   `{"key":"app:region","label":"prod","value":"northeurope"}`,
+			wantQuery: "api-version=1.0&label=prod",
+			want:      "northeurope",
+			wantErr:   false,
+		},
+		{
+			name: "unknown key",
+			args: args{
+				storeName: "store_name",
+				keyArgs:   []string{"unknown"},
+			},
+			resStatus: http.StatusNotFound,
+			wantQuery: "api-version=1.0",
+			want:      "",
+			wantErr:   true,
+		},
+		{
+			name: "too many arguments",
+			args: args{
+				storeName: "store_name",
+				keyArgs:   []string{"app:region", "prod", "extra"},
+			},
+			want:    "",
+			wantErr: true,
+		},
+	}
+
+	sender := &mockSender{}
+	client := newClient("https://fake.azconfig.io", &FakeCredential{}, &policy.ClientOptions{Transport: sender})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			azureClients[tt.args.storeName] = client
+
+			if tt.resStatus == 0 {
+				tt.resStatus = http.StatusOK
+			}
+
+			sender.doFunc = func(r *http.Request) (*http.Response, error) {
+				if r.URL.RawQuery != tt.wantQuery {
+					t.Errorf("GetAppConf() query = %v, want %v", r.URL.RawQuery, tt.wantQuery)
+				}
+				return &http.Response{
+					StatusCode: tt.resStatus,
+					Header:     http.Header{},
+					Body:       io.NopCloser(strings.NewReader(tt.resBody)),
+					Request:    r,
+				}, nil
+			}
+
+			got, err := GetAppConf(tt.args.storeName, tt.args.keyArgs...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetAppConf() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetAppConf() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetFeature(t *testing.T) {
+	tests := []struct {
+		name     string
+		flagName string
+		resBody  string
+		want     bool
+		wantErr  bool
+	}{
+		{
+			name:     "enabled flag",
+			flagName: "beta",
+			resBody:  `{"key":".appconfig.featureflag/beta","value":"{\"id\":\"beta\",\"enabled\":true}"}`,
+			want:     true,
+		},
+		{
+			name:     "disabled flag",
+			flagName: "legacy",
+			resBody:  `{"key":".appconfig.featureflag/legacy","value":"{\"id\":\"legacy\",\"enabled\":false}"}`,
+			want:     false,
+		},
+		{
+			name:     "invalid flag",
+			flagName: "broken",
+			resBody:  `{"key":".appconfig.featureflag/broken","value":"not a json"}`,
+			wantErr:  true,
+		},
+	}
+
+	sender := &mockSender{}
+	azureClients["flags"] = newClient("https://fake.azconfig.io", &FakeCredential{}, &policy.ClientOptions{Transport: sender})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sender.doFunc = func(r *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{},
+					Body:       io.NopCloser(strings.NewReader(tt.resBody)),
+					Request:    r,
+				}, nil
+			}
+			got, err := GetFeature("flags", tt.flagName)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetFeature() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("GetFeature() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseKeyVaultRef(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:  "secret without version",
+			value: `{"uri":"https://my-vault.vault.azure.net/secrets/db-password"}`,
+			want:  []string{"my-vault", "db-password", ""},
+		},
+		{
+			name:  "secret with version",
+			value: `{"uri":"https://my-vault.vault.azure.net/secrets/db-password/c0ffee"}`,
+			want:  []string{"my-vault", "db-password", "c0ffee"},
+		},
+		{
+			name:    "not a secret",
+			value:   `{"uri":"https://my-vault.vault.azure.net/keys/db-key"}`,
+			wantErr: true,
+		},
+		{
+			name:    "not a json",
+			value:   `https://my-vault.vault.azure.net/secrets/db-password`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vault, name, ver, err := parseKeyVaultRef(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseKeyVaultRef() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && !reflect.DeepEqual([]string{vault, name, ver}, tt.want) {
+				t.Errorf("parseKeyVaultRef() got = %v, want %v", []string{vault, name, ver}, tt.want)
+			}
+		})
+	}
+}
+
+type FakeCredential struct{}
+
+func (f *FakeCredential) GetToken(context.Context, policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{Token: "faketoken", ExpiresOn: time.Now().Add(time.Hour).UTC()}, nil
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/keyvault/azcertificates"
+	"github.com/librucha/krmgen/internal/template/offline"
 	"strings"
 )
 
@@ -27,6 +28,9 @@ func ResolveCert(vaultName string, certArgs ...string) (any, error) {
 }
 
 func getCertFromAzure(vaultName string, certName string, certVer string) (string, error) {
+	if offline.Enabled() {
+		return offline.Resolve(CertFunc, vaultName, certName, certVer), nil
+	}
 	secretId := newId(vaultName, certName, certVer)
 	cached := getFromCache(secretId)
 	if cached != nil {
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/keyvault/azkeys"
+	"github.com/librucha/krmgen/internal/template/offline"
 	"strings"
 )
 
@@ -27,6 +28,9 @@ func ResolveKey(vaultName string, keyArgs ...string) (any, error) {
 }
 
 func getCertFromAzure(vaultName string, keyName string, keyVer string) (string, error) {
+	if offline.Enabled() {
+		return offline.Resolve(KeyFunc, vaultName, keyName, keyVer), nil
+	}
 	secretId := newId(vaultName, keyName, keyVer)
 	cached := getFromCache(secretId)
 	if cached != nil {
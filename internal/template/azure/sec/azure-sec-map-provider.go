@@ -0,0 +1,109 @@
+package azsec
+
+import (
+	"context"
+	"fmt"
+	"github.com/Azure/azure-sdk-for-go/sdk/keyvault/azsecrets"
+	"github.com/librucha/krmgen/internal/template/offline"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const SecMapFunc = "azSecMap"
+
+// maxParallelFetches limits concurrent requests to one vault
+const maxParallelFetches = 8
+
+// GetSecretMap returns all enabled secrets of the vault matching given filter.
+// Filter in form "tagName=tagValue" matches secrets by tag otherwise it is used as secret name prefix.
+func GetSecretMap(vaultName string, filterArgs ...string) (map[string]any, error) {
+	switch len(filterArgs) {
+	case 0:
+		return getSecretMapFromAzure(vaultName, "")
+	case 1:
+		return getSecretMapFromAzure(vaultName, filterArgs[0])
+	default:
+		return nil, fmt.Errorf("wrong arguments count for function %q expected 0 or 1 aruments but got %d", SecMapFunc, len(filterArgs))
+	}
+}
+
+func getSecretMapFromAzure(vaultName string, filter string) (map[string]any, error) {
+	if offline.Enabled() {
+		// tags are not known offline so only prefix filter is applied
+		_, _, byTag := strings.Cut(filter, "=")
+		if byTag {
+			return map[string]any{}, nil
+		}
+		return offline.ResolvePrefixed(SecFunc, vaultName, filter), nil
+	}
+	client, err := getClient(vaultName)
+	if err != nil {
+		return nil, err
+	}
+	names, err := listSecretNames(client, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	var firstErr error
+	result := make(map[string]any, len(names))
+	semaphore := make(chan struct{}, maxParallelFetches)
+	for _, name := range names {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(name string) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			value, err := getSecretFromAzure(vaultName, name, "")
+			lock.Lock()
+			defer lock.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("fetching secret %q from vault %q failed error: %s", name, vaultName, err)
+				}
+				return
+			}
+			result[name] = value
+		}(name)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return result, nil
+}
+
+func listSecretNames(client *azsecrets.Client, filter string) ([]string, error) {
+	tagName, tagValue, byTag := strings.Cut(filter, "=")
+	var names []string
+	pager := client.NewListSecretsPager(nil)
+	for pager.More() {
+		page, err := pager.NextPage(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range page.Value {
+			if item.ID == nil {
+				continue
+			}
+			if item.Attributes != nil && item.Attributes.Enabled != nil && !*item.Attributes.Enabled {
+				continue
+			}
+			name := item.ID.Name()
+			if byTag {
+				value, ok := item.Tags[tagName]
+				if !ok || value == nil || *value != tagValue {
+					continue
+				}
+			} else if !strings.HasPrefix(name, filter) {
+				continue
+			}
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
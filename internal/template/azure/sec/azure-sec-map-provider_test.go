@@ -0,0 +1,106 @@
+package azsec
+
+import (
+	"fmt"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/keyvault/azsecrets"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGetSecretMap(t *testing.T) {
+	listBody := `{"value":[
+		{"id":"https://map_vault.vault.azure.net/secrets/app-db-user","attributes":{"enabled":true},"tags":{"app":"db"}},
+		{"id":"https://map_vault.vault.azure.net/secrets/app-db-password","attributes":{"enabled":true},"tags":{"app":"db"}},
+		{"id":"https://map_vault.vault.azure.net/secrets/app-disabled","attributes":{"enabled":false}},
+		{"id":"https://map_vault.vault.azure.net/secrets/other","attributes":{"enabled":true},"tags":{"app":"web"}}
+	]}`
+	values := map[string]string{
+		"app-db-user":     "admin",
+		"app-db-password": "s3cr3t",
+		"other":           "other-value",
+	}
+	tests := []struct {
+		name    string
+		filter  []string
+		want    map[string]any
+		wantErr bool
+	}{
+		{
+			name:   "by prefix",
+			filter: []string{"app-db-"},
+			want:   map[string]any{"app-db-user": "admin", "app-db-password": "s3cr3t"},
+		},
+		{
+			name:   "by tag",
+			filter: []string{"app=web"},
+			want:   map[string]any{"other": "other-value"},
+		},
+		{
+			name:   "all enabled",
+			filter: nil,
+			want:   map[string]any{"app-db-user": "admin", "app-db-password": "s3cr3t", "other": "other-value"},
+		},
+		{
+			name:   "no match",
+			filter: []string{"unknown-"},
+			want:   map[string]any{},
+		},
+		{
+			name:    "too many arguments",
+			filter:  []string{"app-", "extra"},
+			wantErr: true,
+		},
+	}
+
+	sender := &mockSender{}
+	headers := http.Header{}
+	headers.Set("WWW-Authenticate", `Bearer authorization="https://login.windows.net/d5069782-a6df-436e-bac4-67b0c78175c8", resource="not_empty"`)
+	sender.doFunc = func(r *http.Request) (*http.Response, error) {
+		body := listBody
+		status := http.StatusOK
+		name := strings.Trim(strings.TrimPrefix(r.URL.Path, "/secrets"), "/")
+		if name != "" {
+			value, ok := values[name]
+			if ok {
+				body = fmt.Sprintf(`{"id":"https://map_vault.vault.azure.net/secrets/%s/v1","value":%q}`, name, value)
+			} else {
+				status = http.StatusNotFound
+				body = `{"error":{"code":"SecretNotFound"}}`
+			}
+		}
+		return &http.Response{
+			StatusCode: status,
+			Header:     headers,
+			Body:       io.NopCloser(strings.NewReader(body)),
+		}, nil
+	}
+	options := azsecrets.ClientOptions{
+		ClientOptions: azcore.ClientOptions{
+			Transport: sender,
+		},
+		DisableChallengeResourceVerification: true,
+	}
+	client, _ := azsecrets.NewClient("https://map_vault.vault.azure.net", &FakeCredential{}, &options)
+	azureClients["map_vault"] = client
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GetSecretMap("map_vault", tt.filter...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetSecretMap() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetSecretMap() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if cached := getFromCache(newId("map_vault", "app-db-user", "")); cached == nil || *cached.Value != "admin" {
+		t.Errorf("GetSecretMap() did not populate secrets cache")
+	}
+}
@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/keyvault/azsecrets"
+	"github.com/librucha/krmgen/internal/template/offline"
 	"strings"
+	"sync"
 )
 
 const SecFunc = "azSec"
@@ -16,6 +18,8 @@ var azureClients = make(map[string]*azsecrets.Client, 10)
 
 var cachedSecrets = make(map[azsecrets.ID]*azsecrets.SecretBundle, 50)
 
+var cacheLock sync.RWMutex
+
 func GetSecret(vaultName string, keyArgs ...string) (any, error) {
 	switch len(keyArgs) {
 	case 1:
@@ -36,6 +40,9 @@ func ToPemBlock(blockType string, text string) (string, error) {
 }
 
 func getSecretFromAzure(vaultName string, keyId string, keyVer string) (string, error) {
+	if offline.Enabled() {
+		return offline.Resolve(SecFunc, vaultName, keyId, keyVer), nil
+	}
 	secretId := newId(vaultName, keyId, keyVer)
 	cached := getFromCache(secretId)
 	if cached != nil {
@@ -49,6 +56,7 @@ func getSecretFromAzure(vaultName string, keyId string, keyVer string) (string,
 	if err != nil {
 		return "", err
 	}
+	saveToCache(secretId, &secret.SecretBundle)
 	saveToCache(*secret.ID, &secret.SecretBundle)
 	return *secret.Value, nil
 }
@@ -79,6 +87,8 @@ func getVaultUrl(vaultName string) string {
 }
 
 func getFromCache(id azsecrets.ID) *azsecrets.SecretBundle {
+	cacheLock.RLock()
+	defer cacheLock.RUnlock()
 	cached := cachedSecrets[id]
 	if cached == nil {
 		return nil
@@ -87,5 +97,7 @@ func getFromCache(id azsecrets.ID) *azsecrets.SecretBundle {
 }
 
 func saveToCache(id azsecrets.ID, secret *azsecrets.SecretBundle) {
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
 	cachedSecrets[id] = secret
 }
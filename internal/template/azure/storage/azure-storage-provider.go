@@ -4,6 +4,7 @@ import (
 	"context"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storage/armstorage"
+	"github.com/librucha/krmgen/internal/template/offline"
 	"strings"
 )
 
@@ -16,6 +17,9 @@ var azureClients = make(map[string]*armstorage.AccountsClient, 10)
 var cachedKeys = make(map[storageId]*armstorage.AccountKey, 50)
 
 func GetStoreKey(subscriptionID string, resourceGroupName string, storageAccountName string) (string, error) {
+	if offline.Enabled() {
+		return offline.Resolve(StoreKeyFunc, subscriptionID, resourceGroupName, storageAccountName), nil
+	}
 	id := newId(subscriptionID, resourceGroupName, storageAccountName)
 	cached := getFromCache(id)
 	if cached != nil {
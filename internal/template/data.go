@@ -0,0 +1,60 @@
+package template
+
+import (
+	types "github.com/librucha/krmgen/internal"
+)
+
+// Data is the root object templates are executed with
+type Data struct {
+	// File is the path of currently evaluated file
+	File string
+	// WorkDir is the directory being generated
+	WorkDir string
+	// Metadata of the KrmGen config
+	Metadata *types.Metadata
+	// Chart is available only when helm values are evaluated
+	Chart *ChartData
+	// Vars declared in the KrmGen config merged with active profile vars
+	Vars map[string]any
+	// Profile is name of the active profile
+	Profile string
+	// templates settings of the KrmGen config
+	templates *types.Templates
+}
+
+type ChartData struct {
+	Name    string
+	Release string
+	Version string
+	Repo    string
+}
+
+func NewData(config *types.Config, workDir string) *Data {
+	data := &Data{WorkDir: workDir}
+	if config != nil {
+		data.Metadata = config.Metadata
+		data.Vars = config.Vars
+		data.Profile = config.Profile
+		data.templates = config.Templates
+	}
+	return data
+}
+
+// ForFile returns copy of data for evaluation of given file
+func (d *Data) ForFile(file string) *Data {
+	data := *d
+	data.File = file
+	return &data
+}
+
+// ForChart returns copy of data for evaluation of given helm chart values
+func (d *Data) ForChart(chart *types.HelmChart) *Data {
+	data := *d
+	data.Chart = &ChartData{
+		Name:    chart.Name,
+		Release: chart.ReleaseName,
+		Version: chart.Version,
+		Repo:    chart.RepoUrl,
+	}
+	return &data
+}
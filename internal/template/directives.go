@@ -0,0 +1,115 @@
+package template
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const directivePrefix = "krmgen:"
+const skipDirective = "skip"
+const delimsDirective = "delims"
+const templateDirective = "template"
+
+// directives are declared in header comments of evaluated file like
+//
+//	# krmgen:skip
+//	# krmgen:delims [[ ]]
+//	# krmgen:template
+type directives struct {
+	skip   bool
+	delims []string
+	// template opts in evaluation of files which are not evaluated by default like Helm values files
+	template bool
+	// headerEnd is the offset of content after the last directive line
+	headerEnd int
+}
+
+// parseDirectives reads krmgen directives from leading comment lines of content
+func parseDirectives(content string) (directives, error) {
+	var result directives
+	offset := 0
+	for _, rawLine := range strings.SplitAfter(content, "\n") {
+		offset += len(rawLine)
+		line := strings.TrimSpace(rawLine)
+		if line == "" {
+			continue
+		}
+		comment, isComment := strings.CutPrefix(line, "#")
+		if !isComment {
+			break
+		}
+		directive, isDirective := strings.CutPrefix(strings.TrimSpace(comment), directivePrefix)
+		if !isDirective {
+			continue
+		}
+		fields := strings.Fields(directive)
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case skipDirective:
+			result.skip = true
+		case delimsDirective:
+			if len(fields) != 3 {
+				return result, fmt.Errorf("directive %q expects left and right delimiter", line)
+			}
+			result.delims = fields[1:]
+		case templateDirective:
+			result.template = true
+		default:
+			return result, fmt.Errorf("unknown directive %q", line)
+		}
+		result.headerEnd = offset
+	}
+	return result, nil
+}
+
+// matchesAny returns true if relative path of file matches any of given globs.
+// Globs support ** for any number of directories. Globs without slash are matched to file name.
+func matchesAny(globs []string, file string, workDir string) bool {
+	if file == "" || len(globs) == 0 {
+		return false
+	}
+	relPath := file
+	if workDir != "" {
+		if rel, err := filepath.Rel(workDir, file); err == nil {
+			relPath = rel
+		}
+	}
+	relPath = filepath.ToSlash(relPath)
+	for _, glob := range globs {
+		target := relPath
+		if !strings.Contains(glob, "/") {
+			target = filepath.Base(relPath)
+		}
+		if globToRegexp(glob).MatchString(target) {
+			return true
+		}
+	}
+	return false
+}
+
+func globToRegexp(glob string) *regexp.Regexp {
+	pattern := strings.Builder{}
+	pattern.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; {
+		case strings.HasPrefix(glob[i:], "**/"):
+			pattern.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			pattern.WriteString(".*")
+			i++
+		case c == '*':
+			pattern.WriteString("[^/]*")
+		case c == '?':
+			pattern.WriteString("[^/]")
+		default:
+			pattern.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	pattern.WriteString("$")
+	return regexp.MustCompile(pattern.String())
+}
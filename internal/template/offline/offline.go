@@ -0,0 +1,75 @@
+package offline
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"strings"
+)
+
+var enabled = false
+
+var fixtures = make(map[string]string)
+
+// Enable switches secret functions to offline mode. They return values from fixtures file
+// or deterministic placeholders instead of calling remote services.
+func Enable(fixturesFile string) error {
+	if fixturesFile != "" {
+		content, err := os.ReadFile(fixturesFile)
+		if err != nil {
+			return fmt.Errorf("reading offline fixtures file %q failed error: %s", fixturesFile, err)
+		}
+		loaded := make(map[string]string)
+		if err := yaml.Unmarshal(content, &loaded); err != nil {
+			return fmt.Errorf("unmarshaling offline fixtures file %q failed error: %s", fixturesFile, err)
+		}
+		fixtures = loaded
+	}
+	enabled = true
+	return nil
+}
+
+// Disable switches secret functions back to online mode
+func Disable() {
+	enabled = false
+	fixtures = make(map[string]string)
+}
+
+func Enabled() bool {
+	return enabled
+}
+
+// Resolve returns fixture value for given function call or placeholder like <azSec:vault/name>
+func Resolve(funcName string, args ...string) string {
+	key := Key(funcName, args...)
+	value, found := fixtures[key]
+	if found {
+		return value
+	}
+	return "<" + key + ">"
+}
+
+// ResolvePrefixed returns all fixtures of function whose key starts with given prefix.
+// Keys of returned map are stripped of function and base path.
+func ResolvePrefixed(funcName string, base string, prefix string) map[string]any {
+	keyPrefix := Key(funcName, base) + "/"
+	result := make(map[string]any)
+	for key, value := range fixtures {
+		name, found := strings.CutPrefix(key, keyPrefix)
+		if found && strings.HasPrefix(name, prefix) {
+			result[name] = value
+		}
+	}
+	return result
+}
+
+// Key returns fixture key for given function call like azSec:vault/name
+func Key(funcName string, args ...string) string {
+	var parts []string
+	for _, arg := range args {
+		if arg != "" {
+			parts = append(parts, arg)
+		}
+	}
+	return funcName + ":" + strings.Join(parts, "/")
+}
@@ -0,0 +1,85 @@
+package offline
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+	fixturesFile := filepath.Join(t.TempDir(), "fixtures.yaml")
+	_ = os.WriteFile(fixturesFile, []byte("azSec:vault/db-password: s3cr3t\nazStoreKey:sub/rg/account: storeKey\n"), 0666)
+	if err := Enable(fixturesFile); err != nil {
+		t.Fatalf("Enable() error = %v", err)
+	}
+	defer Disable()
+
+	type args struct {
+		funcName string
+		args     []string
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{
+			name: "fixture value",
+			args: args{funcName: "azSec", args: []string{"vault", "db-password", ""}},
+			want: "s3cr3t",
+		},
+		{
+			name: "fixture value for multiple arguments",
+			args: args{funcName: "azStoreKey", args: []string{"sub", "rg", "account"}},
+			want: "storeKey",
+		},
+		{
+			name: "placeholder",
+			args: args{funcName: "azSec", args: []string{"vault", "unknown", ""}},
+			want: "<azSec:vault/unknown>",
+		},
+		{
+			name: "placeholder with version",
+			args: args{funcName: "azCert", args: []string{"vault", "tls", "v1"}},
+			want: "<azCert:vault/tls/v1>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Resolve(tt.args.funcName, tt.args.args...); got != tt.want {
+				t.Errorf("Resolve() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolvePrefixed(t *testing.T) {
+	fixturesFile := filepath.Join(t.TempDir(), "fixtures.yaml")
+	_ = os.WriteFile(fixturesFile, []byte("azSec:vault/app-user: admin\nazSec:vault/app-password: s3cr3t\nazSec:vault/other: other\nazSec:other-vault/app-user: nobody\n"), 0666)
+	if err := Enable(fixturesFile); err != nil {
+		t.Fatalf("Enable() error = %v", err)
+	}
+	defer Disable()
+
+	want := map[string]any{"app-user": "admin", "app-password": "s3cr3t"}
+	if got := ResolvePrefixed("azSec", "vault", "app-"); !reflect.DeepEqual(got, want) {
+		t.Errorf("ResolvePrefixed() = %v, want %v", got, want)
+	}
+}
+
+func TestEnable(t *testing.T) {
+	defer Disable()
+	if err := Enable(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Errorf("Enable() expected error for missing fixtures file")
+	}
+	if Enabled() {
+		t.Errorf("Enabled() = true after failed Enable()")
+	}
+	if err := Enable(""); err != nil {
+		t.Errorf("Enable() error = %v", err)
+	}
+	if !Enabled() {
+		t.Errorf("Enabled() = false, want true")
+	}
+}
@@ -1,9 +1,12 @@
 package template
 
 import (
+	"fmt"
 	"github.com/Masterminds/goutils"
 	"github.com/Masterminds/sprig/v3"
+	"github.com/librucha/krmgen/internal/placeholder"
 	"github.com/librucha/krmgen/internal/template/argocd"
+	azappconf "github.com/librucha/krmgen/internal/template/azure/appconf"
 	azcert "github.com/librucha/krmgen/internal/template/azure/cert"
 	azkey "github.com/librucha/krmgen/internal/template/azure/key"
 	azsec "github.com/librucha/krmgen/internal/template/azure/sec"
@@ -27,6 +30,7 @@ func initFuncs(t *template.Template) {
 
 	// Add Azure key vault secrets
 	funcs[azsec.SecFunc] = azsec.GetSecret
+	funcs[azsec.SecMapFunc] = azsec.GetSecretMap
 	funcs[azsec.ToPemFunc] = azsec.ToPemBlock
 	// Add Azure key vault certificates
 	funcs[azcert.CertFunc] = azcert.ResolveCert
@@ -34,6 +38,9 @@ func initFuncs(t *template.Template) {
 	funcs[azkey.KeyFunc] = azkey.ResolveKey
 	// Add Azure storage key
 	funcs[azstorage.StoreKeyFunc] = azstorage.GetStoreKey
+	// Add Azure app configuration key-values and feature flags
+	funcs[azappconf.AppConfFunc] = azappconf.GetAppConf
+	funcs[azappconf.FeatureFunc] = azappconf.GetFeature
 
 	// Add ArgoCD env function
 	funcs[argocd.EnvFunc] = argocd.ResolveArgocdEnv
@@ -47,18 +54,75 @@ func initFuncs(t *template.Template) {
 	t.Funcs(funcs)
 }
 
-func EvalGoTemplates(content string) (string, error) {
+const defaultLeftDelim = "{{"
+const defaultRightDelim = "}}"
+
+// EvalGoTemplates evaluates Go templates in content with given data as root object
+func EvalGoTemplates(content string, data *Data) (string, error) {
+	return evalGoTemplates(content, data, defaultLeftDelim, defaultRightDelim)
+}
+
+// HasTemplateDirective returns true if header directives of content opt in template evaluation
+func HasTemplateDirective(content string) (bool, error) {
+	fileDirectives, err := parseDirectives(content)
+	if err != nil {
+		return false, err
+	}
+	return fileDirectives.template, nil
+}
+
+// EvalFileTemplates evaluates Go templates and env placeholders in file content.
+// Header directives of the content and config templates settings from data can change delimiters or skip evaluation.
+func EvalFileTemplates(content string, data *Data) (string, error) {
+	if data == nil {
+		data = &Data{}
+	}
+	fileDirectives, err := parseDirectives(content)
+	if err != nil {
+		return "", fmt.Errorf("parsing directives of %q failed error: %s", data.File, err)
+	}
+	settings := data.templates
+	if fileDirectives.skip || (settings != nil && matchesAny(settings.Skip, data.File, data.WorkDir)) {
+		return content, nil
+	}
+	delims := fileDirectives.delims
+	if delims == nil && settings != nil && len(settings.Delims) > 0 {
+		delims = settings.Delims
+	}
+	if delims == nil {
+		delims = []string{defaultLeftDelim, defaultRightDelim}
+	}
+	if len(delims) != 2 || delims[0] == "" || delims[1] == "" {
+		return "", fmt.Errorf("template delimiters %v of %q must be exactly left and right delimiter", delims, data.File)
+	}
+	// directives header is kept as is
+	header, body := content[:fileDirectives.headerEnd], content[fileDirectives.headerEnd:]
+	evaluated, err := evalGoTemplates(body, data, delims[0], delims[1])
+	if err != nil {
+		return "", err
+	}
+	evaluated, err = placeholder.EvalEnvPlaceholders(evaluated)
+	if err != nil {
+		return "", err
+	}
+	return header + evaluated, nil
+}
+
+func evalGoTemplates(content string, data *Data, leftDelim string, rightDelim string) (string, error) {
 	if goutils.IsBlank(content) {
 		return content, nil
 	}
-	t := template.New("krmgen")
+	t := template.New("krmgen").Delims(leftDelim, rightDelim)
 	initFuncs(t)
 	tmpl, err := t.Parse(content)
 	if err != nil {
 		return "", err
 	}
+	if data == nil {
+		data = &Data{}
+	}
 	var buffer strings.Builder
-	if err := tmpl.Execute(&buffer, nil); err != nil {
+	if err := tmpl.Execute(&buffer, data); err != nil {
 		return "", err
 	}
 	return buffer.String(), nil
@@ -1,6 +1,7 @@
 package template
 
 import (
+	types "github.com/librucha/krmgen/internal"
 	"github.com/librucha/krmgen/internal/template/argocd"
 	"os"
 	"testing"
@@ -9,6 +10,7 @@ import (
 func Test_EvalGoTemplates(t *testing.T) {
 	type args struct {
 		text string
+		data *Data
 	}
 	tests := []struct {
 		name    string
@@ -18,50 +20,71 @@ func Test_EvalGoTemplates(t *testing.T) {
 	}{
 		{
 			name: "builtin function",
-			args: args{`Prefix {{ print "hello" }} suffix`},
+			args: args{text: `Prefix {{ print "hello" }} suffix`},
 			want: "Prefix hello suffix",
 		},
 		{
 			name: "sprig function",
-			args: args{`Prefix {{ upper "hello" }} suffix`},
+			args: args{text: `Prefix {{ upper "hello" }} suffix`},
 			want: "Prefix HELLO suffix",
 		},
 		{
 			name: "empty input",
-			args: args{""},
+			args: args{text: ""},
 			want: "",
 		},
 		{
 			name: "blank input",
-			args: args{" \t"},
+			args: args{text: " \t"},
 			want: " \t",
 		},
 		// Rainy scenarios
 		{
 			name:    "sprig env function",
-			args:    args{`Prefix {{ env "PATH" }} suffix`},
+			args:    args{text: `Prefix {{ env "PATH" }} suffix`},
 			wantErr: true,
 		},
 		{
 			name:    "sprig expandenv function",
-			args:    args{`Prefix {{ expandenv "PATH" }} suffix`},
+			args:    args{text: `Prefix {{ expandenv "PATH" }} suffix`},
 			wantErr: true,
 		},
 		// ArgoCD env
 		{
 			name: "argocd existing env",
-			args: args{`Prefix {{ argocdEnv "TEST_KEY" }} suffix`},
+			args: args{text: `Prefix {{ argocdEnv "TEST_KEY" }} suffix`},
 			want: "Prefix ArgoCD data suffix",
 		},
 		{
 			name: "argocd existing env with default",
-			args: args{`Prefix {{ argocdEnv "TEST_KEY" "not used" }} suffix`},
+			args: args{text: `Prefix {{ argocdEnv "TEST_KEY" "not used" }} suffix`},
 			want: "Prefix ArgoCD data suffix",
 		},
+		// Template data
+		{
+			name: "vars data",
+			args: args{text: `Prefix {{ .Vars.region }} suffix`, data: &Data{Vars: map[string]any{"region": "westeurope"}}},
+			want: "Prefix westeurope suffix",
+		},
+		{
+			name: "file data",
+			args: args{text: `Prefix {{ base .File }} suffix`, data: (&Data{WorkDir: "/tmp"}).ForFile("/tmp/cm.yaml")},
+			want: "Prefix cm.yaml suffix",
+		},
+		{
+			name: "chart data",
+			args: args{text: `Prefix {{ .Chart.Release }}-{{ .Chart.Version }} suffix`, data: (&Data{}).ForChart(&types.HelmChart{ReleaseName: "app", Version: "1.2.3"})},
+			want: "Prefix app-1.2.3 suffix",
+		},
+		{
+			name: "missing vars data",
+			args: args{text: `Prefix {{ .Vars.region }} suffix`},
+			want: "Prefix <no value> suffix",
+		},
 		// unknown func
 		{
 			name:    "unknown func",
-			args:    args{"Prefix {{`{{ anyTotallyUnknownFunc }}`}} suffix"},
+			args:    args{text: "Prefix {{`{{ anyTotallyUnknownFunc }}`}} suffix"},
 			want:    `Prefix {{ anyTotallyUnknownFunc }} suffix`,
 			wantErr: false,
 		},
@@ -69,7 +92,7 @@ func Test_EvalGoTemplates(t *testing.T) {
 	for _, tt := range tests {
 		_ = os.Setenv(argocd.EnvEnvKeyPrefix+"TEST_KEY", "ArgoCD data")
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := EvalGoTemplates(tt.args.text)
+			got, err := EvalGoTemplates(tt.args.text, tt.args.data)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("EvalGoTemplates() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -80,3 +103,89 @@ func Test_EvalGoTemplates(t *testing.T) {
 		})
 	}
 }
+
+func Test_EvalFileTemplates(t *testing.T) {
+	settings := &types.Config{Templates: &types.Templates{
+		Delims: []string{"[[", "]]"},
+		Skip:   []string{"dashboards/**/*.json", "rules.yaml"},
+	}}
+	type args struct {
+		content string
+		data    *Data
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "default delimiters",
+			args: args{content: `value: {{ upper "hello" }}`},
+			want: "value: HELLO",
+		},
+		{
+			name: "env placeholder",
+			args: args{content: `value: ${env:ARGOCD_ENV_UNKNOWN_KEY:fallback}`},
+			want: "value: fallback",
+		},
+		{
+			name: "header delimiters",
+			args: args{content: "# krmgen:delims [[ ]]\nvalue: [[ upper \"hello\" ]] {{ .Values.name }}"},
+			want: "# krmgen:delims [[ ]]\nvalue: HELLO {{ .Values.name }}",
+		},
+		{
+			name: "header skip",
+			args: args{content: "# krmgen:skip\nvalue: {{ .Values.name }} ${env:PWD}"},
+			want: "# krmgen:skip\nvalue: {{ .Values.name }} ${env:PWD}",
+		},
+		{
+			name: "header template",
+			args: args{content: "# krmgen:template\nvalue: {{ upper \"hello\" }}"},
+			want: "# krmgen:template\nvalue: HELLO",
+		},
+		{
+			name: "config delimiters",
+			args: args{content: `value: [[ upper "hello" ]] {{ .Values.name }}`, data: NewData(settings, "/work").ForFile("/work/cm.yaml")},
+			want: "value: HELLO {{ .Values.name }}",
+		},
+		{
+			name: "config skip glob",
+			args: args{content: `{"expr": "{{ $labels.instance }}"}`, data: NewData(settings, "/work").ForFile("/work/dashboards/team/app.json")},
+			want: `{"expr": "{{ $labels.instance }}"}`,
+		},
+		{
+			name: "config skip file name",
+			args: args{content: `summary: {{ $value }}`, data: NewData(settings, "/work").ForFile("/work/monitoring/rules.yaml")},
+			want: `summary: {{ $value }}`,
+		},
+		// Rainy scenarios
+		{
+			name:    "invalid header delimiters",
+			args:    args{content: "# krmgen:delims [[\nvalue: test"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown directive",
+			args:    args{content: "# krmgen:unknown\nvalue: test"},
+			wantErr: true,
+		},
+		{
+			name:    "helm template without skip",
+			args:    args{content: `value: {{ .Values.name }}`},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := EvalFileTemplates(tt.args.content, tt.args.data)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("EvalFileTemplates() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("EvalFileTemplates() got = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
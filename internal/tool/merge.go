@@ -0,0 +1,20 @@
+package tool
+
+// MergeMaps deep merges src onto dst and returns new map.
+// Nested maps are merged recursively, any other values (including lists) from src replace values in dst.
+func MergeMaps(dst map[string]any, src map[string]any) map[string]any {
+	result := make(map[string]any, len(dst)+len(src))
+	for k, v := range dst {
+		result[k] = v
+	}
+	for k, v := range src {
+		srcMap, srcIsMap := v.(map[string]any)
+		dstMap, dstIsMap := result[k].(map[string]any)
+		if srcIsMap && dstIsMap {
+			result[k] = MergeMaps(dstMap, srcMap)
+		} else {
+			result[k] = v
+		}
+	}
+	return result
+}
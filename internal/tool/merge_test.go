@@ -0,0 +1,63 @@
+package tool
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeMaps(t *testing.T) {
+	type args struct {
+		dst map[string]any
+		src map[string]any
+	}
+	tests := []struct {
+		name string
+		args args
+		want map[string]any
+	}{
+		{
+			name: "both empty",
+			args: args{},
+			want: map[string]any{},
+		},
+		{
+			name: "override scalar",
+			args: args{
+				dst: map[string]any{"replicas": 1, "name": "app"},
+				src: map[string]any{"replicas": 3},
+			},
+			want: map[string]any{"replicas": 3, "name": "app"},
+		},
+		{
+			name: "merge nested maps",
+			args: args{
+				dst: map[string]any{"image": map[string]any{"repository": "app", "tag": "1.0.0"}},
+				src: map[string]any{"image": map[string]any{"tag": "2.0.0"}, "debug": true},
+			},
+			want: map[string]any{"image": map[string]any{"repository": "app", "tag": "2.0.0"}, "debug": true},
+		},
+		{
+			name: "replace lists",
+			args: args{
+				dst: map[string]any{"args": []any{"a", "b"}},
+				src: map[string]any{"args": []any{"c"}},
+			},
+			want: map[string]any{"args": []any{"c"}},
+		},
+		{
+			name: "replace map by scalar",
+			args: args{
+				dst: map[string]any{"resources": map[string]any{"cpu": 1}},
+				src: map[string]any{"resources": nil},
+			},
+			want: map[string]any{"resources": nil},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MergeMaps(tt.args.dst, tt.args.src); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MergeMaps() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package tool
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var byteSizeUnits = []struct {
+	suffix     string
+	multiplier int64
+}{
+	{"Ki", 1 << 10},
+	{"Mi", 1 << 20},
+	{"Gi", 1 << 30},
+	{"K", 1000},
+	{"M", 1000 * 1000},
+	{"G", 1000 * 1000 * 1000},
+}
+
+// ParseByteSize parses size like 512Mi, 1G or plain number of bytes
+func ParseByteSize(input string) (int64, error) {
+	size := strings.TrimSpace(input)
+	if size == "" {
+		return 0, nil
+	}
+	multiplier := int64(1)
+	for _, unit := range byteSizeUnits {
+		if strings.HasSuffix(size, unit.suffix) {
+			size, multiplier = strings.TrimSuffix(size, unit.suffix), unit.multiplier
+			break
+		}
+	}
+	value, err := strconv.ParseInt(size, 10, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("size %q is not valid expected number with optional unit K, M, G, Ki, Mi or Gi", input)
+	}
+	return value * multiplier, nil
+}
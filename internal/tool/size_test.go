@@ -0,0 +1,37 @@
+package tool
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    string
+		want    int64
+		wantErr bool
+	}{
+		{name: "empty", size: "", want: 0},
+		{name: "bytes", size: "1024", want: 1024},
+		{name: "binary unit", size: "512Mi", want: 512 << 20},
+		{name: "decimal unit", size: "2G", want: 2000000000},
+		{name: "unknown unit", size: "1T", wantErr: true},
+		{name: "negative", size: "-1Mi", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseByteSize(tt.size)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseByteSize() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if err != nil && !strings.Contains(err.Error(), `"`+tt.size+`"`) {
+				t.Errorf("ParseByteSize() error = %v, want original input %q", err, tt.size)
+			}
+			if got != tt.want {
+				t.Errorf("ParseByteSize() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,13 +2,32 @@ package tool
 
 import (
 	"bytes"
+	"os"
 	"os/exec"
+	"strings"
 )
 
 func RunCommand(name string, arg ...string) (stdOut string, stdErr string, err error) {
+	return RunCommandWithEnv(nil, "", name, arg...)
+}
+
+// RunCommandWithInput runs command with given standard input
+func RunCommandWithInput(stdIn string, name string, arg ...string) (stdOut string, stdErr string, err error) {
+	return RunCommandWithEnv(nil, stdIn, name, arg...)
+}
+
+// RunCommandWithEnv runs command with given standard input and env variables added to current environment.
+// Empty input and env run the command with no input in current environment.
+func RunCommandWithEnv(env []string, stdIn string, name string, arg ...string) (stdOut string, stdErr string, err error) {
 	cmd := exec.Command(name, arg...)
 	var outBuffer bytes.Buffer
 	var errBuffer bytes.Buffer
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	if stdIn != "" {
+		cmd.Stdin = strings.NewReader(stdIn)
+	}
 	cmd.Stdout = &outBuffer
 	cmd.Stderr = &errBuffer
 	runError := cmd.Run()
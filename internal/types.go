@@ -5,16 +5,108 @@ import (
 )
 
 type Config struct {
-	ApiVersion string    `yaml:"apiVersion"`
-	Kind       string    `yaml:"kind"`
-	Metadata   *Metadata `yaml:"metadata"`
-	Helm       *Helm     `yaml:"helm"`
+	ApiVersion string              `yaml:"apiVersion"`
+	Kind       string              `yaml:"kind"`
+	Metadata   *Metadata           `yaml:"metadata"`
+	Helm       *Helm               `yaml:"helm"`
+	Vars       map[string]any      `yaml:"vars"`
+	Templates  *Templates          `yaml:"templates"`
+	Kustomize  *Kustomize          `yaml:"kustomize"`
+	Profiles   map[string]*Profile `yaml:"profiles"`
+	// Profile is name of the active profile
+	Profile string `yaml:"-"`
 }
 
 func (config Config) HasHelm() bool {
 	return config.Helm != nil && config.Helm.Charts != nil && len(*config.Helm.Charts) > 0
 }
 
+// Templates settings of krmgen template evaluation
+type Templates struct {
+	// Delims are left and right template delimiters
+	Delims []string `yaml:"delims"`
+	// Skip lists path globs relative to work dir excluded from template evaluation
+	Skip []string `yaml:"skip"`
+}
+
+// Kustomize settings of kustomize build
+type Kustomize struct {
+	// HelmOutput controls how rendered helm charts are added to kustomization
+	HelmOutput *HelmOutput `yaml:"helmOutput"`
+	// Options of kustomize build
+	Options *KustomizeOptions `yaml:"options"`
+}
+
+// KustomizeOptions are flags of kustomize build. Each option can be overridden by ArgoCD env.
+type KustomizeOptions struct {
+	// LoadRestrictor is LoadRestrictionsRootOnly or LoadRestrictionsNone allowing files outside kustomization dir
+	LoadRestrictor string `yaml:"loadRestrictor"`
+	// EnableHelm enables helmCharts inflation by kustomize
+	EnableHelm bool `yaml:"enableHelm"`
+	// EnableAlphaPlugins enables kustomize plugins
+	EnableAlphaPlugins bool `yaml:"enableAlphaPlugins"`
+	// EnableExec enables exec KRM functions
+	EnableExec bool `yaml:"enableExec"`
+	// Reorder of output resources is legacy or none
+	Reorder string `yaml:"reorder"`
+	// NetworkEnabled enables network access of KRM function containers
+	NetworkEnabled bool `yaml:"networkEnabled"`
+}
+
+// HelmOutput places rendered helm charts into kustomization.
+// Charts referenced explicitly by helm://<releaseName> are not added automatically.
+// Patch target helm://<releaseName> selects resources of the release.
+type HelmOutput struct {
+	// Mode is "single" for one file with all charts or "perChart" for one kustomization dir per chart
+	Mode string `yaml:"mode"`
+	// Position of charts in resources of kustomization is "append", "prepend" or "none"
+	Position string `yaml:"position"`
+}
+
+// RenderedChart is helm template output of one chart release
+type RenderedChart struct {
+	ReleaseName string
+	Manifest    string
+}
+
+// Profile overlays config defaults when selected
+type Profile struct {
+	Vars map[string]any `yaml:"vars"`
+	// Charts overlays keyed by chart release name
+	Charts map[string]*ProfileChart `yaml:"charts"`
+}
+
+type ProfileChart struct {
+	Version      string         `yaml:"version"`
+	ValuesInline map[string]any `yaml:"valuesInline"`
+}
+
+// Lock pins helm chart versions and digests resolved by krmgen lock
+type Lock struct {
+	ApiVersion string        `yaml:"apiVersion"`
+	Kind       string        `yaml:"kind"`
+	Charts     []LockedChart `yaml:"charts"`
+}
+
+// LockedChart is exact version and digest of chart resolved from version constraint
+type LockedChart struct {
+	ReleaseName string `yaml:"releaseName"`
+	Name        string `yaml:"name"`
+	RepoUrl     string `yaml:"repo"`
+	Constraint  string `yaml:"constraint"`
+	Version     string `yaml:"version"`
+	Digest      string `yaml:"digest"`
+	// Dependencies are packages built by helm dependency build of local and git charts
+	Dependencies []LockedDependency `yaml:"dependencies,omitempty"`
+}
+
+// LockedDependency is digest of dependency package in charts dir of locked chart
+type LockedDependency struct {
+	// File is package file name like redis-17.0.0.tgz
+	File   string `yaml:"file"`
+	Digest string `yaml:"digest"`
+}
+
 type Metadata struct {
 	Labels      map[string]string `yaml:"labels"`
 	Annotations map[string]string `yaml:"annotations"`
@@ -22,6 +114,29 @@ type Metadata struct {
 
 type Helm struct {
 	Charts *[]HelmChart `yaml:"charts"`
+	// Repositories are referenced by charts like repo: "@name"
+	Repositories []HelmRepository `yaml:"repositories"`
+}
+
+// HelmRepository declares repo url and its credentials once for many charts
+type HelmRepository struct {
+	Name     string `yaml:"name"`
+	Url      string `yaml:"url"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	// UsernameEnv is name of env variable with username used when Username is empty
+	UsernameEnv string `yaml:"usernameEnv"`
+	// PasswordEnv is name of env variable with password used when Password is empty
+	PasswordEnv string `yaml:"passwordEnv"`
+	// CaFile is CA bundle of repo server relative to work dir or inline PEM
+	CaFile string `yaml:"caFile"`
+	// CertFile is client certificate relative to work dir or inline PEM
+	CertFile string `yaml:"certFile"`
+	// KeyFile is client certificate key relative to work dir or inline PEM
+	KeyFile               string `yaml:"keyFile"`
+	InsecureSkipTlsVerify bool   `yaml:"insecureSkipTlsVerify"`
+	// PlainHttp uses insecure HTTP connections to oci registry
+	PlainHttp bool `yaml:"plainHttp"`
 }
 
 type HelmChart struct {
@@ -33,6 +148,93 @@ type HelmChart struct {
 	Version      string         `yaml:"version"`
 	ValuesInline map[string]any `yaml:"valuesInline"`
 	ValuesFile   string         `yaml:"valuesFile"`
+	// Namespace of the release. Defaults to ArgoCD app namespace.
+	Namespace string `yaml:"namespace"`
+	// KubeVersion for chart capabilities. Defaults to ArgoCD KUBE_VERSION.
+	KubeVersion string `yaml:"kubeVersion"`
+	// ApiVersions for chart capabilities. Defaults to ArgoCD KUBE_API_VERSIONS.
+	ApiVersions []string `yaml:"apiVersions"`
+	// ValuesFiles are applied in given order after ValuesFile. Glob patterns are expanded in lexical order.
+	// Krmgen templates are evaluated only in values files with krmgen:template header directive.
+	ValuesFiles []string `yaml:"valuesFiles"`
+	// ValuesGlobal are merged over ValuesInline into global values shared with all subcharts
+	ValuesGlobal map[string]any `yaml:"valuesGlobal"`
+	// ValuesSubcharts are merged over ValuesInline into values of subcharts keyed by subchart name or alias
+	ValuesSubcharts map[string]map[string]any `yaml:"valuesSubcharts"`
+	// IncludeCrds renders CRDs of crds dir. Defaults to true.
+	IncludeCrds *bool `yaml:"includeCrds"`
+	// SkipTests omits helm test hooks from output
+	SkipTests bool `yaml:"skipTests"`
+	// Hooks is handling of helm hooks keep, drop or convert-to-argocd. Defaults to keep.
+	Hooks string `yaml:"hooks"`
+	// SkipSchemaValidation disables validation of values against values.schema.json
+	SkipSchemaValidation bool `yaml:"skipSchemaValidation"`
+	// PostRender steps are applied in given order to helm output
+	PostRender []PostRenderStep `yaml:"postRender"`
+	// Digest pins sha256 digest of chart package like sha256:<hex>. Filled from krmgen.lock when locked.
+	Digest string `yaml:"digest"`
+	// LockedDependencies pins dependency packages of local and git charts. Filled from krmgen.lock when locked.
+	LockedDependencies []LockedDependency `yaml:"-"`
+	// Repositories are declared helm repositories resolving dependencies of the chart. Filled from helm.repositories.
+	Repositories []HelmRepository `yaml:"-"`
+	// Verify chart provenance for helm repo charts or signature for oci charts before templating
+	Verify bool `yaml:"verify"`
+	// Keyring is public keyring of provenance or cosign public key relative to work dir
+	Keyring string `yaml:"keyring"`
+	// VerifyWith selects oci signature verifier cosign or notation. Defaults to cosign.
+	VerifyWith string `yaml:"verifyWith"`
+	// CaFile is CA bundle of repo server relative to work dir or inline PEM
+	CaFile string `yaml:"caFile"`
+	// CertFile is client certificate relative to work dir or inline PEM
+	CertFile string `yaml:"certFile"`
+	// KeyFile is client certificate key relative to work dir or inline PEM
+	KeyFile               string `yaml:"keyFile"`
+	InsecureSkipTlsVerify bool   `yaml:"insecureSkipTlsVerify"`
+	// PlainHttp uses insecure HTTP connections to oci registry
+	PlainHttp bool `yaml:"plainHttp"`
+}
+
+// PostRenderStep transforms helm chart output. Exactly one transformation is expected.
+type PostRenderStep struct {
+	// Json6902 applies RFC 6902 operations to selected resources
+	Json6902 *Json6902Patch `yaml:"json6902"`
+	// StrategicMerge is a partial resource merged to the resource with the same kind and name
+	StrategicMerge map[string]any `yaml:"strategicMerge"`
+	// Remove drops selected resources
+	Remove *ResourceSelector `yaml:"remove"`
+	// Namespace forces namespace of all namespaced resources
+	Namespace string `yaml:"namespace"`
+	// ClusterScopedKinds are extra kinds of cluster scoped custom resources skipped by Namespace
+	ClusterScopedKinds []string `yaml:"clusterScopedKinds"`
+	// Exec pipes resources through external command
+	Exec *ExecStep `yaml:"exec"`
+}
+
+type Json6902Patch struct {
+	Target     *ResourceSelector    `yaml:"target"`
+	Operations []JsonPatchOperation `yaml:"operations"`
+}
+
+// JsonPatchOperation is single RFC 6902 operation
+type JsonPatchOperation struct {
+	Op    string `yaml:"op"`
+	Path  string `yaml:"path"`
+	From  string `yaml:"from"`
+	Value any    `yaml:"value"`
+}
+
+// ResourceSelector selects resources by all non-empty fields
+type ResourceSelector struct {
+	Group     string `yaml:"group"`
+	Version   string `yaml:"version"`
+	Kind      string `yaml:"kind"`
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace"`
+}
+
+type ExecStep struct {
+	Command string   `yaml:"command"`
+	Args    []string `yaml:"args"`
 }
 
 type SecretFuncMap struct {
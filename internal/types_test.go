@@ -25,12 +25,12 @@ func TestConfig_HasHelm(t *testing.T) {
 		},
 		{
 			name:   "Has nil helm charts",
-			fields: fields{Helm: &Helm{nil}},
+			fields: fields{Helm: &Helm{Charts: nil}},
 			want:   false,
 		},
 		{
 			name:   "Has empty helm charts",
-			fields: fields{Helm: &Helm{&[]HelmChart{}}},
+			fields: fields{Helm: &Helm{Charts: &[]HelmChart{}}},
 			want:   false,
 		},
 	}
@@ -7,3 +7,14 @@ const EnvHelmUsername = EnvPrefix + "HELM_USERNAME"
 const EnvHelmPassword = EnvPrefix + "HELM_PASSWORD"
 
 const EnvKubectlExecutable = EnvPrefix + "KUBECTL_EXECUTABLE"
+
+// EnvPlaceholderEnvPrefixes is comma separated list of env prefixes allowed in ${env:NAME} placeholders
+const EnvPlaceholderEnvPrefixes = EnvPrefix + "PLACEHOLDER_ENV_PREFIXES"
+
+const EnvGitExecutable = EnvPrefix + "GIT_EXECUTABLE"
+
+const EnvCosignExecutable = EnvPrefix + "COSIGN_EXECUTABLE"
+const EnvNotationExecutable = EnvPrefix + "NOTATION_EXECUTABLE"
+
+const EnvChartCacheDir = EnvPrefix + "CHART_CACHE_DIR"
+const EnvChartCacheMaxSize = EnvPrefix + "CHART_CACHE_MAX_SIZE"